@@ -13,41 +13,85 @@ import (
 
 // icecastNormalizerFeeder reads chunks from Icecast source, normalizes them, and feeds to MusicReader
 // It automatically manages mode switching based on whether an Icecast source is connected
+// and whether the dead-air watchdog considers the live audio healthy
+// Switches go through a handover (fade, wait for track end or crossfade) instead of a hard cut
 func icecastNormalizerFeeder() {
 	modules.Logger.Info("Icecast normalizer feeder started")
 	var isIcecastProcessing bool
+	var isSourceConnected bool
 	var processorWaitCh chan struct{}
+	var handoverCh <-chan struct{} // Non-nil while automation is handing over to live
+	var liveHead []byte            // Live audio collected during a crossfade handover
 
 	for {
 		// Check if there's an active Icecast source connection
 		hasSource := modules.IcecastSource.HasActiveSource()
 
-		// Transition: Source connected, start Icecast mode
-		if hasSource && !isIcecastProcessing {
-			modules.Logger.Info("Icecast source connected - switching to Icecast mode")
-			
-			// Close current file and advance to next song before switching modes
-			// This ensures: 1) current file is released (no lock for cleanup), 2) next song is ready when Icecast disconnects
-			modules.MusicReader.SkipToNext()
-			modules.Logger.Info("Advancing to next song in preparation for Icecast mode")
-			
-			modules.MusicReader.EnableIcecastMode()
-			
-			// Create a channel to signal when processor is done
-			processorWaitCh = make(chan struct{})
-			go func() {
-				modules.MusicReader.ProcessIcecastStream()
-				close(processorWaitCh)
-			}()
-			
-			isIcecastProcessing = true
-			time.Sleep(200 * time.Millisecond) // Give processor time to start
-			continue
+		// Track source sessions so the watchdog starts fresh for every connection
+		if hasSource && !isSourceConnected {
+			modules.LiveWatchdog.Start(modules.IcecastSource.ContentType())
+			modules.LiveRecorder.StartSession(modules.IcecastSource.LiveTitle())
+			isSourceConnected = true
+		} else if !hasSource && isSourceConnected {
+			modules.LiveWatchdog.Stop()
+			modules.LiveRecorder.StopSession()
+			isSourceConnected = false
+		}
+
+		// Live audio goes on air only while the watchdog sees no dead air
+		liveOK := hasSource && modules.LiveWatchdog.Healthy()
+
+		// Transition: Source connected (or recovered from dead air), start handing over to live
+		if liveOK && !isIcecastProcessing && handoverCh == nil {
+			modules.Logger.Info(fmt.Sprintf("Icecast source connected - handing over to live (%s)", modules.Config.HandoverMode))
+			handoverCh = modules.MusicReader.HandOverToLive(modules.Config.HandoverMode)
+			liveHead = nil
 		}
 
-		// Transition: Source disconnected, revert to file mode
-		if !hasSource && isIcecastProcessing {
-			modules.Logger.Info("Icecast source disconnected - reverting to file mode")
+		// Source left or went silent before the handover completed - stay on automation
+		if !liveOK && handoverCh != nil {
+			modules.MusicReader.CancelHandover()
+			handoverCh = nil
+			liveHead = nil
+		}
+
+		// Handover complete: automation has released the air, start Icecast mode
+		if handoverCh != nil {
+			select {
+			case <-handoverCh:
+				handoverCh = nil
+				modules.MusicReader.EnableIcecastMode()
+				modules.AnnounceLive()
+
+				// Create a channel to signal when processor is done
+				processorWaitCh = make(chan struct{})
+				go func() {
+					modules.MusicReader.ProcessIcecastStream()
+					close(processorWaitCh)
+				}()
+				isIcecastProcessing = true
+
+				// Crossfade: the mix of automation tail and live head goes on air first
+				if modules.Config.HandoverMode == modules.HandoverCrossfade {
+					mixed := modules.CrossfadeAudio(modules.MusicReader.TakeHandoverTail(), liveHead, modules.Config.HandoverCrossfadeMs)
+					if mixed == nil {
+						mixed = liveHead
+					}
+					feedLiveAudio(mixed)
+				}
+				liveHead = nil
+				continue
+			default:
+			}
+		}
+
+		// Transition: Source disconnected or dead air detected, revert to file mode
+		if !liveOK && isIcecastProcessing {
+			if hasSource {
+				modules.Logger.Info("Icecast source has dead air - reverting to file mode")
+			} else {
+				modules.Logger.Info("Icecast source disconnected - reverting to file mode")
+			}
 			modules.MusicReader.DisableIcecastMode()
 			
 			// Wait for processor to exit (with timeout)
@@ -63,19 +107,36 @@ func icecastNormalizerFeeder() {
 			continue
 		}
 
-		// No source and not processing - check periodically
-		if !hasSource && !isIcecastProcessing {
+		// No source - check periodically
+		if !hasSource {
 			time.Sleep(500 * time.Millisecond)
 			continue
 		}
 
-		// Source is active and we're processing - get next chunk
+		// Source is connected - get next chunk
 		chunk, ok := modules.IcecastSource.GetAudioChunk()
 		if !ok {
 			time.Sleep(10 * time.Millisecond)
 			continue
 		}
 
+		// The watchdog keeps measuring even during fallback so it can detect recovery
+		modules.LiveWatchdog.Observe(chunk)
+		modules.LiveRecorder.RecordInput(chunk)
+
+		// Handing over: keep the live audio for the crossfade, otherwise drop it until we are on air
+		if handoverCh != nil {
+			if modules.Config.HandoverMode == modules.HandoverCrossfade {
+				liveHead = append(liveHead, chunk...)
+			}
+			continue
+		}
+
+		// Falling back to automation - drain live audio without putting it on air
+		if !isIcecastProcessing {
+			continue
+		}
+
 		// For live streaming, pass chunks through directly (no re-encoding)
 		// Icecast already provides MP3 data from Mixxx
 		// Skip FFmpeg normalization to avoid latency/jerkiness
@@ -88,6 +149,22 @@ func icecastNormalizerFeeder() {
 	}
 }
 
+// feedLiveAudio feeds a block of audio to the live processor in source-sized chunks
+func feedLiveAudio(data []byte) {
+	const chunkSize = 4096
+	for len(data) > 0 {
+		n := chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		err := modules.MusicReader.FeedIcecastChunk(data[:n])
+		if err != nil {
+			modules.Logger.Debug("Failed to feed chunk: " + err.Error())
+		}
+		data = data[n:]
+	}
+}
+
 func main() {
 
 	modules.InitReader()
@@ -107,6 +184,12 @@ func main() {
 	e := echo.New()
 
 	e.HideBanner = true
+	// Listener votes and requests are keyed by IP, so forwarded headers are only trusted behind a proxy
+	if modules.Config.TrustProxy {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
 	e.HTTPErrorHandler = middlewares.CustomHTTPErrorHandler
 	e.Use(middlewares.LoggerIn)
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
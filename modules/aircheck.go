@@ -0,0 +1,305 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Name of the output tap used by the aircheck recorder
+const aircheckTap = "aircheck"
+
+// Aircheck segment files are named aircheck-YYYYMMDD-HHMM.mp3, play logs aircheck-YYYYMMDD.jsonl
+var aircheckSegmentPattern = regexp.MustCompile(`^aircheck-([0-9]{8}-[0-9]{4})\.mp3$`)
+var aircheckLogPattern = regexp.MustCompile(`^aircheck-([0-9]{8})\.jsonl$`)
+
+// AircheckSegment describes one rotated aircheck recording
+type AircheckSegment struct {
+	Name  string `json:"name"`
+	Start int64  `json:"start"` // Unix milliseconds
+	End   int64  `json:"end"`   // Unix milliseconds
+	Size  int64  `json:"size"`
+}
+
+// IAircheck continuously records the outgoing stream in rotated files with a play log
+type IAircheck struct {
+	mu           sync.Mutex
+	file         *os.File
+	segmentStart time.Time
+	segmentEnd   time.Time
+}
+
+var Aircheck = &IAircheck{}
+
+// StartAircheck starts recording the outgoing stream when aircheck is enabled
+func StartAircheck() {
+	if !Config.AircheckEnabled {
+		return
+	}
+
+	if err := os.MkdirAll(Config.AircheckDir, 0755); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create aircheck directory, aircheck disabled: %v", err))
+		return
+	}
+
+	AddOutputTap(aircheckTap, Aircheck.write)
+	AddOnAirListener(Aircheck.logItem)
+	StartAircheckCleanupRoutine()
+
+	Logger.Info(fmt.Sprintf("Aircheck recording to %s (rotation: %d minutes, retention: %d days)", Config.AircheckDir, Config.AircheckRotationMinutes, Config.AircheckRetentionDays))
+}
+
+// segmentBounds returns the rotation period containing t, aligned to local midnight
+func segmentBounds(t time.Time) (time.Time, time.Time) {
+	rotation := time.Duration(Config.AircheckRotationMinutes) * time.Minute
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	start := midnight.Add(t.Sub(midnight) / rotation * rotation)
+	end := start.Add(rotation)
+
+	// Never let a segment cross midnight so each day's files stand on their own
+	nextMidnight := midnight.AddDate(0, 0, 1)
+	if end.After(nextMidnight) {
+		end = nextMidnight
+	}
+	return start, end
+}
+
+func (a *IAircheck) write(data []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.file == nil || !now.Before(a.segmentEnd) {
+		a.rotateLocked(now)
+	}
+	if a.file == nil {
+		return
+	}
+
+	if _, err := a.file.Write(data); err != nil {
+		Logger.Error(fmt.Sprintf("Aircheck write failed: %v", err))
+		a.file.Close()
+		a.file = nil
+	}
+}
+
+// rotateLocked closes the current segment and opens the one covering now
+// Restarting within a period appends to the existing segment
+func (a *IAircheck) rotateLocked(now time.Time) {
+	if a.file != nil {
+		if err := a.file.Close(); err != nil {
+			Logger.Error(err)
+		}
+		a.file = nil
+	}
+
+	start, end := segmentBounds(now)
+	name := fmt.Sprintf("aircheck-%s.mp3", start.Format("20060102-1504"))
+	file, err := os.OpenFile(filepath.Join(Config.AircheckDir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Failed to open aircheck segment: %v", err))
+		return
+	}
+
+	a.file = file
+	a.segmentStart = start
+	a.segmentEnd = end
+	Logger.Info(fmt.Sprintf("Aircheck segment started: %s", name))
+}
+
+func (a *IAircheck) logItem(item OnAirItem) {
+	a.appendLog(item)
+}
+
+// appendLog adds a play log entry to the daily aircheck log
+func (a *IAircheck) appendLog(item OnAirItem) {
+	day := time.UnixMilli(item.Time).Format("20060102")
+	path := filepath.Join(Config.AircheckDir, fmt.Sprintf("aircheck-%s.jsonl", day))
+
+	line, err := json.Marshal(item)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Failed to open aircheck log: %v", err))
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to write aircheck log: %v", err))
+	}
+}
+
+// AircheckSegments returns the segments overlapping [from, to), oldest first
+func AircheckSegments(from, to time.Time) ([]AircheckSegment, error) {
+	entries, err := os.ReadDir(Config.AircheckDir)
+	if os.IsNotExist(err) {
+		return []AircheckSegment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []AircheckSegment{}
+	for _, entry := range entries {
+		match := aircheckSegmentPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		start, err := time.ParseInLocation("20060102-1504", match[1], time.Local)
+		if err != nil {
+			continue
+		}
+		_, end := segmentBounds(start)
+		if !end.After(from) || !start.Before(to) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, AircheckSegment{
+			Name:  entry.Name(),
+			Start: start.UnixMilli(),
+			End:   end.UnixMilli(),
+			Size:  info.Size(),
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments, nil
+}
+
+// AircheckLog returns the play log entries in [from, to), oldest first
+// The entry that was already on air at from is included as the first item
+func AircheckLog(from, to time.Time) ([]OnAirItem, error) {
+	items := []OnAirItem{}
+	var previous *OnAirItem
+
+	// Walk the daily logs from the day before from, to find what was on air at from
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, -1)
+	for !day.After(to) {
+		path := filepath.Join(Config.AircheckDir, fmt.Sprintf("aircheck-%s.jsonl", day.Format("20060102")))
+		day = day.AddDate(0, 0, 1)
+
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var item OnAirItem
+			if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+				continue
+			}
+			itemTime := time.UnixMilli(item.Time)
+			if itemTime.Before(from) {
+				entry := item
+				previous = &entry
+				continue
+			}
+			if !itemTime.Before(to) {
+				break
+			}
+			items = append(items, item)
+		}
+		file.Close()
+	}
+
+	if previous != nil && (len(items) == 0 || items[0].Time > from.UnixMilli()) {
+		items = append([]OnAirItem{*previous}, items...)
+	}
+	return items, nil
+}
+
+// AircheckPath resolves an aircheck segment name to its path
+func AircheckPath(name string) (string, error) {
+	if !aircheckSegmentPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid aircheck segment name")
+	}
+	path := filepath.Join(Config.AircheckDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("aircheck segment not found")
+	}
+	return path, nil
+}
+
+// CleanOldAircheck deletes segments and logs older than the retention period
+func CleanOldAircheck() error {
+	if Config.AircheckRetentionDays <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(Config.AircheckDir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -Config.AircheckRetentionDays)
+	deleted := 0
+	for _, entry := range entries {
+		var stamp string
+		if match := aircheckSegmentPattern.FindStringSubmatch(entry.Name()); match != nil {
+			stamp = match[1][:8]
+		} else if match := aircheckLogPattern.FindStringSubmatch(entry.Name()); match != nil {
+			stamp = match[1]
+		} else {
+			continue
+		}
+
+		day, err := time.ParseInLocation("20060102", stamp, time.Local)
+		if err != nil || !day.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(Config.AircheckDir, entry.Name())); err != nil {
+			Logger.Error(fmt.Sprintf("Failed to delete aircheck file %s: %v", entry.Name(), err))
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		Logger.Info(fmt.Sprintf("Aircheck cleanup: deleted %d files older than %d days", deleted, Config.AircheckRetentionDays))
+	}
+	return nil
+}
+
+// StartAircheckCleanupRoutine periodically applies the aircheck retention
+func StartAircheckCleanupRoutine() {
+	if Config.AircheckRetentionDays <= 0 {
+		return
+	}
+
+	go func() {
+		if err := CleanOldAircheck(); err != nil {
+			Logger.Error(fmt.Sprintf("Initial aircheck cleanup failed: %v", err))
+		}
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := CleanOldAircheck(); err != nil {
+				Logger.Error(fmt.Sprintf("Scheduled aircheck cleanup failed: %v", err))
+			}
+		}
+	}()
+}
+
@@ -0,0 +1,265 @@
+package modules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// ErrNoArtwork is returned for songs without embedded artwork or a cover image
+var ErrNoArtwork = errors.New("no artwork")
+
+// Cover images looked up in the folder of a song without embedded artwork
+var coverFileNames = []string{"cover.jpg", "folder.jpg", "cover.jpeg", "folder.jpeg", "cover.png", "folder.png"}
+
+// Largest artwork size that can be requested, in pixels
+const maxArtworkSize = 1200
+
+// Artwork sizes that can be requested, in pixels. Other sizes are rounded up to
+// the next one (or artwork_size), so each song has a few cached images at most.
+var artworkSizes = []int{64, 128, 300, 600, maxArtworkSize}
+
+// Largest artwork image that is decoded, in pixels (width x height)
+const maxArtworkPixels = 50000000
+
+type artworkSourceEntry struct {
+	modTime time.Time // Of the song file
+	cover   string    // Cover image in the song's folder, if the song has no embedded artwork
+	found   bool
+}
+
+// Where the artwork of each song comes from, by song path
+var artworkSources = &sync.Map{}
+
+// artworkSource finds the artwork of a song: embedded (cover is empty) or a cover image
+func artworkSource(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if cached, ok := artworkSources.Load(path); ok {
+		entry := cached.(artworkSourceEntry)
+		if entry.modTime.Equal(info.ModTime()) {
+			if entry.cover == "" {
+				return "", entry.found
+			}
+			if _, err := os.Stat(entry.cover); err == nil {
+				return entry.cover, true
+			}
+		}
+	}
+
+	entry := artworkSourceEntry{modTime: info.ModTime()}
+	if _, err := embeddedArtwork(path); err == nil {
+		entry.found = true
+	} else {
+		entry.cover, entry.found = folderCover(filepath.Dir(path))
+	}
+	artworkSources.Store(path, entry)
+	return entry.cover, entry.found
+}
+
+// embeddedArtwork returns the front cover of a song's ID3 tag, or its first picture
+func embeddedArtwork(path string) ([]byte, error) {
+	tag, err := id3v2.Open(path, id3v2.Options{Parse: true, ParseFrames: []string{"Attached picture"}})
+	if err != nil {
+		return nil, err
+	}
+	defer tag.Close()
+
+	var picture []byte
+	for _, frame := range tag.GetFrames(tag.CommonID("Attached picture")) {
+		pictureFrame, ok := frame.(id3v2.PictureFrame)
+		if !ok || len(pictureFrame.Picture) == 0 {
+			continue
+		}
+		if pictureFrame.PictureType == id3v2.PTFrontCover {
+			return pictureFrame.Picture, nil
+		}
+		if picture == nil {
+			picture = pictureFrame.Picture
+		}
+	}
+	if picture == nil {
+		return nil, ErrNoArtwork
+	}
+	return picture, nil
+}
+
+// folderCover finds a cover image in a folder, ignoring case
+func folderCover(dir string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, name := range coverFileNames {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(entry.Name(), name) {
+				return filepath.Join(dir, entry.Name()), true
+			}
+		}
+	}
+	return "", false
+}
+
+// HasArtwork reports whether a song has embedded artwork or a cover image
+func HasArtwork(hash string) bool {
+	path, ok := FindSongByHash(hash)
+	if !ok {
+		return false
+	}
+	_, found := artworkSource(path)
+	return found
+}
+
+// ArtworkURL returns the artwork URL of a song, or "" when it has none
+func ArtworkURL(hash string) string {
+	if hash == "" || !HasArtwork(hash) {
+		return ""
+	}
+	return "/art/" + hash
+}
+
+// Artwork returns the path of a cached JPEG of a song's artwork, scaled to fit size pixels
+func Artwork(hash string, size int) (string, error) {
+	path, ok := FindSongByHash(hash)
+	if !ok {
+		return "", ErrSongNotFound
+	}
+	size = snapArtworkSize(size)
+
+	cover, found := artworkSource(path)
+	if !found {
+		return "", ErrNoArtwork
+	}
+	source := path
+	if cover != "" {
+		source = cover
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+
+	// Editing the tags or replacing the cover changes the modification time and so the cache key
+	dir := filepath.Join(Config.CacheDir, "art")
+	cached := filepath.Join(dir, fmt.Sprintf("%s-%d-%d.jpg", hash, size, info.ModTime().UnixNano()))
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+
+	var data []byte
+	if cover == "" {
+		data, err = embeddedArtwork(path)
+	} else {
+		data, err = os.ReadFile(cover)
+	}
+	if err != nil {
+		return "", err
+	}
+	// Check the dimensions first: decoding allocates the whole image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("unsupported artwork: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxArtworkPixels {
+		return "", fmt.Errorf("unsupported artwork: %dx%d pixels", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("unsupported artwork: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(img, size), &jpeg.Options{Quality: 85}); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmpPath := cached + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, cached); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// snapArtworkSize rounds a requested size up to the nearest of artworkSizes or
+// artwork_size. Zero selects artwork_size, sizes above the largest get the largest.
+func snapArtworkSize(size int) int {
+	if size <= 0 {
+		return Config.ArtworkSize
+	}
+	snapped := 0
+	for _, allowed := range append([]int{Config.ArtworkSize}, artworkSizes...) {
+		if allowed >= size && (snapped == 0 || allowed < snapped) {
+			snapped = allowed
+		}
+	}
+	if snapped == 0 {
+		return maxArtworkSize
+	}
+	return snapped
+}
+
+// scaleImage shrinks an image to fit within size x size by averaging the covered pixels
+func scaleImage(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	newWidth, newHeight := size, size
+	if width > height {
+		newHeight = height * size / width
+	} else {
+		newWidth = width * size / height
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := bounds.Min.Y + (y+1)*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := bounds.Min.X + (x+1)*width/newWidth
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+			scaled.Set(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+	return scaled
+}
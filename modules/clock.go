@@ -0,0 +1,298 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotationCategory is a pool of songs that clock wheel positions draw from.
+// The pool is a folder or M3U source (or the whole library), narrowed by optional tag filters.
+type RotationCategory struct {
+	Source   string `json:"source"`    // Folder or .m3u file, relative to the music directory, "playlist:NAME" or "smart:NAME"; empty means the whole library
+	Genre    string `json:"genre"`     // Only songs with this genre tag
+	Artist   string `json:"artist"`    // Only songs by this artist
+	Album    string `json:"album"`     // Only songs from this album
+	YearFrom int    `json:"year_from"` // Only songs released in or after this year
+	YearTo   int    `json:"year_to"`   // Only songs released in or before this year
+	Order    string `json:"order"`     // "shuffle" or "sequential"; empty follows the global random flag
+}
+
+// ClockWheelConfig is an hourly rotation template: a sequence of categories
+// played in order from the top of every hour, repeating if the hour is not over
+type ClockWheelConfig struct {
+	Name  string   `json:"name"`
+	Slots []string `json:"slots"` // Category names
+}
+
+// ClockStatus describes the clock wheel in use
+type ClockStatus struct {
+	Active       bool     `json:"active"`
+	Clock        string   `json:"clock,omitempty"`
+	HourStart    int64    `json:"hour_start,omitempty"` // Unix milliseconds
+	Position     int      `json:"position"`             // Index of the slot the most recent pick came from (-1 = none yet)
+	Category     string   `json:"category,omitempty"`   // Category of the most recent pick
+	NextPosition int      `json:"next_position"`
+	NextCategory string   `json:"next_category,omitempty"`
+	Slots        []string `json:"slots,omitempty"`
+}
+
+// IClockWheel selects songs by walking the active clock wheel
+type IClockWheel struct {
+	mu       sync.Mutex
+	clock    string
+	hour     time.Time
+	picks    int               // Picks made from the wheel this hour
+	position int               // Slot index of the most recent pick
+	last     map[string]string // Last hash picked per category
+}
+
+var ClockWheel = &IClockWheel{
+	position: -1,
+	last:     make(map[string]string),
+}
+
+// PrepareClocks validates categories and clock wheels, and the clocks referenced by the schedule
+func PrepareClocks(categories map[string]RotationCategory, clocks []ClockWheelConfig, defaultClock string, schedule []ScheduleSlot) error {
+	for name, category := range categories {
+		category.Order = strings.ToLower(category.Order)
+		if category.Order != "" && category.Order != ScheduleOrderShuffle && category.Order != ScheduleOrderSequential {
+			return fmt.Errorf("category %s: order must be shuffle or sequential", name)
+		}
+		categories[name] = category
+	}
+
+	names := make(map[string]bool)
+	for _, clock := range clocks {
+		if clock.Name == "" {
+			return fmt.Errorf("every clock needs a name")
+		}
+		if names[clock.Name] {
+			return fmt.Errorf("duplicate clock %s", clock.Name)
+		}
+		names[clock.Name] = true
+
+		if len(clock.Slots) == 0 {
+			return fmt.Errorf("clock %s has no slots", clock.Name)
+		}
+		for _, slot := range clock.Slots {
+			if _, ok := categories[slot]; !ok {
+				return fmt.Errorf("clock %s: unknown category %q", clock.Name, slot)
+			}
+		}
+	}
+
+	if defaultClock != "" && !names[defaultClock] {
+		return fmt.Errorf("unknown clock %q", defaultClock)
+	}
+	for _, slot := range schedule {
+		if slot.Clock != "" && !names[slot.Clock] {
+			return fmt.Errorf("schedule slot %s: unknown clock %q", slot.Name, slot.Clock)
+		}
+	}
+	return nil
+}
+
+// activeClock returns the clock wheel in use at now: the clock of the active
+// schedule slot, or the default clock outside scheduled slots
+func activeClock(now time.Time) *ClockWheelConfig {
+	name := Config.Clock
+	if slot, _ := ActiveScheduleSlot(now); slot != nil {
+		name = slot.Clock
+	}
+	if name == "" {
+		return nil
+	}
+
+	for i := range Config.Clocks {
+		if Config.Clocks[i].Name == name {
+			return &Config.Clocks[i]
+		}
+	}
+	return nil
+}
+
+func hourStart(now time.Time) time.Time {
+	return now.Truncate(time.Hour)
+}
+
+func (wheel *IClockWheel) Name() string {
+	return "clock"
+}
+
+// Key changes every hour so a pick made for the previous hour is replaced at the top of the hour
+func (wheel *IClockWheel) Key(now time.Time) string {
+	clock := activeClock(now)
+	if clock == nil {
+		return ""
+	}
+	return fmt.Sprintf("clock:%s@%s", clock.Name, hourStart(now).Format("2006010215"))
+}
+
+// Next picks from the category at the next position of the wheel.
+// Categories that have no songs are skipped.
+func (wheel *IClockWheel) Next(now time.Time) (string, bool) {
+	clock := activeClock(now)
+	if clock == nil {
+		return "", false
+	}
+
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	hour := hourStart(now)
+	if wheel.clock != clock.Name || !wheel.hour.Equal(hour) {
+		// A new hour or a different clock starts at the top of the wheel
+		wheel.clock = clock.Name
+		wheel.hour = hour
+		wheel.picks = 0
+		wheel.position = -1
+	}
+
+	for attempt := 0; attempt < len(clock.Slots); attempt++ {
+		position := wheel.picks % len(clock.Slots)
+		wheel.picks++
+
+		name := clock.Slots[position]
+		category := Config.Categories[name]
+		hashes, err := category.songHashes()
+		if err != nil || len(hashes) == 0 {
+			Logger.Error(fmt.Sprintf("Clock %s: category %s has no songs, skipping: %v", clock.Name, name, err))
+			continue
+		}
+
+		hash := pickNextHash("category:"+name, hashes, wheel.last[name], category.shuffle())
+		wheel.last[name] = hash
+		wheel.position = position
+		Logger.Debug(fmt.Sprintf("Clock %s position %d (%s)", clock.Name, position, name))
+		return hash, true
+	}
+	return "", false
+}
+
+// Status returns the clock wheel in use and the position within it
+func (wheel *IClockWheel) Status() ClockStatus {
+	now := time.Now()
+	clock := activeClock(now)
+	if clock == nil {
+		return ClockStatus{Position: -1, NextPosition: -1}
+	}
+
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	status := ClockStatus{
+		Active:    true,
+		Clock:     clock.Name,
+		HourStart: hourStart(now).UnixMilli(),
+		Slots:     clock.Slots,
+		Position:  -1,
+	}
+
+	picks := 0
+	if wheel.clock == clock.Name && wheel.hour.Equal(hourStart(now)) {
+		picks = wheel.picks
+		status.Position = wheel.position
+		if wheel.position >= 0 {
+			status.Category = clock.Slots[wheel.position]
+		}
+	}
+	status.NextPosition = picks % len(clock.Slots)
+	status.NextCategory = clock.Slots[status.NextPosition]
+	return status
+}
+
+// snapshot returns the position within the wheel for the playout state
+func (wheel *IClockWheel) snapshot() ClockWheelState {
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	state := ClockWheelState{
+		Clock:    wheel.clock,
+		Picks:    wheel.picks,
+		Position: wheel.position,
+		Last:     make(map[string]string, len(wheel.last)),
+	}
+	if !wheel.hour.IsZero() {
+		state.Hour = wheel.hour.UnixMilli()
+	}
+	for category, hash := range wheel.last {
+		state.Last[category] = hash
+	}
+	return state
+}
+
+// restore continues the wheel from a saved position; a saved hour that has passed starts over at the top
+func (wheel *IClockWheel) restore(state ClockWheelState) {
+	wheel.mu.Lock()
+	defer wheel.mu.Unlock()
+
+	for category, hash := range state.Last {
+		wheel.last[category] = hash
+	}
+	if state.Hour == 0 {
+		return
+	}
+	wheel.clock = state.Clock
+	wheel.hour = time.UnixMilli(state.Hour)
+	wheel.picks = state.Picks
+	wheel.position = state.Position
+}
+
+// shuffle reports whether the category plays in random order
+func (category RotationCategory) shuffle() bool {
+	if category.Order != "" {
+		return category.Order == ScheduleOrderShuffle
+	}
+	return Config.Random
+}
+
+// songHashes returns the songs of the category in playback order
+func (category RotationCategory) songHashes() ([]string, error) {
+	var hashes []string
+	if category.Source != "" {
+		var err error
+		if hashes, err = SourceSongHashes(category.Source); err != nil {
+			return nil, err
+		}
+	} else {
+		hashes = SortedSongHashes
+	}
+
+	if !category.hasFilter() {
+		return hashes, nil
+	}
+
+	filtered := []string{}
+	for _, hash := range hashes {
+		if meta, ok := GetTrackMeta(hash); ok && category.matches(meta) {
+			filtered = append(filtered, hash)
+		}
+	}
+	return filtered, nil
+}
+
+func (category RotationCategory) hasFilter() bool {
+	return category.Genre != "" || category.Artist != "" || category.Album != "" || category.YearFrom != 0 || category.YearTo != 0
+}
+
+// matches reports whether a song's tags pass the category filters (case-insensitive)
+func (category RotationCategory) matches(meta TrackMeta) bool {
+	if category.Genre != "" && !strings.EqualFold(meta.Genre, category.Genre) {
+		return false
+	}
+	if category.Artist != "" && !strings.EqualFold(meta.Artist, category.Artist) {
+		return false
+	}
+	if category.Album != "" && !strings.EqualFold(meta.Album, category.Album) {
+		return false
+	}
+	if category.YearFrom != 0 && meta.Year < category.YearFrom {
+		return false
+	}
+	if category.YearTo != 0 && (meta.Year == 0 || meta.Year > category.YearTo) {
+		return false
+	}
+	return true
+}
@@ -18,6 +18,7 @@ import (
 type IConfig struct {
 	Port               int
 	Host               string
+	TrustProxy         bool   // Take client IPs from X-Forwarded-For headers set by a reverse proxy
 	Directory          string
 	Random             bool
 	Debug              bool
@@ -29,6 +30,7 @@ type IConfig struct {
 	StandardSampleRate string // Sample rate for audio normalization (e.g., "44100")
 	CacheDir           string // Directory to store cached normalized files
 	CacheTTLMinutes    int    // Cache time-to-live in minutes (0 = no cleanup)
+	CacheMaxMB         int    // Maximum size of transcoded files in the cache in MB (0 = unlimited)
 	// Shoutcast metadata
 	Genre              string // Shoutcast genre (icy-genre header)
 	URL                string // Shoutcast/Stream URL (icy-url header)
@@ -38,6 +40,77 @@ type IConfig struct {
 	// Authentication
 	Username           string // Username for API authentication
 	Password           string // Password for API authentication
+	// Dead-air watchdog for live sources
+	DeadAirEnabled        bool    // Fall back to automation when the live source goes silent or stalls
+	DeadAirThresholdDb    float64 // Audio level (dBFS) below which the live source counts as silent
+	DeadAirSilenceSeconds int     // Seconds of continuous silence before falling back
+	DeadAirStallSeconds   int     // Seconds without any data from a connected source before falling back
+	DeadAirResumeSeconds  int     // Seconds of audible audio required before returning to live
+	// Live handover
+	HandoverMode        string // How automation hands over to live: "immediate", "wait" or "crossfade"
+	HandoverFadeMs      int    // Fade length in milliseconds for immediate handovers
+	HandoverCrossfadeMs int    // Overlap length in milliseconds for crossfade handovers
+	// Live show recording
+	RecordLive           bool   // Record every live source session to the archive
+	RecordSource         string // What to record: "input" (raw source audio) or "output" (station stream)
+	ArchiveDir           string // Directory for live show recordings
+	ArchiveRetentionDays int    // Days to keep recordings (0 = keep forever)
+	// Aircheck (compliance recording of the outgoing stream)
+	AircheckEnabled         bool   // Continuously record the outgoing stream
+	AircheckDir             string // Directory for aircheck segments and play logs
+	AircheckRotationMinutes int    // Length of each aircheck segment in minutes
+	AircheckRetentionDays   int    // Days to keep aircheck segments and logs (0 = keep forever)
+	// Programming schedule (dayparting)
+	Schedule []ScheduleSlot // Weekday/time slots mapped to music sources
+	// Clock wheels
+	Categories map[string]RotationCategory // Song pools that clock positions draw from
+	Clocks     []ClockWheelConfig          // Hourly rotation templates
+	Clock      string                      // Clock to run outside scheduled slots ("" = none)
+	// Separation rules for random playback
+	TrackSeparationHours    int // Don't repeat a song within this many hours (0 = off)
+	ArtistSeparationMinutes int // Don't repeat an artist within this many minutes (0 = off)
+	AlbumSeparationTracks   int // Don't repeat an album within this many songs (0 = off)
+	// Random playback
+	RandomMode string             // "random", "shuffle" or "weighted"
+	RandomSeed int64              // Seed for reproducible rotations (0 = seed from the clock)
+	Weights    map[string]float64 // Weights by file or folder path relative to the music directory
+	DataDir    string             // Directory for persistent state (shuffle bags, etc.)
+	// Jingles and station IDs
+	JingleDir          string // Directory with jingles ("" = no jingles)
+	JingleEveryTracks  int    // Play a jingle after this many songs (0 = off)
+	JingleEveryMinutes int    // Play a jingle when this many minutes have passed since the last one (0 = off)
+	JingleTitle        string // Stream title while a jingle plays ("" = keep the previous title)
+	// Ad and sponsor spots
+	SpotDir        string        // Directory with spot files
+	Spots          []SpotConfig  // Spots with booking windows and play caps
+	Breaks         []BreakConfig // Groups of spots aired together
+	ProofOfPlayDir string        // Directory for the proof-of-play logs
+	// Listener requests
+	RequestsEnabled      bool // Accept song requests from listeners
+	RequestAutoApprove   bool // Approve requests without moderation
+	RequestLimit         int  // Requests per listener (client IP) per window (0 = unlimited)
+	RequestWindowMinutes int  // Rate limit window in minutes
+	RequestRepeatMinutes int  // Refuse songs played or requested within this many minutes (0 = no limit)
+	RequestMinGapTracks  int  // Songs to play between two requests
+	RequestMaxOpen       int  // Maximum pending and approved requests (0 = unlimited)
+	// Skip votes and ratings
+	SkipVoteEnabled  bool    // Let listeners vote to skip the current song
+	SkipVoteShare    float64 // Share of active listeners whose votes skip the song
+	SkipVoteMin      int     // Minimum number of votes needed to skip
+	DislikeWeighting bool    // Play songs with more dislikes than likes less often
+	// Play history
+	HistoryDir           string // Directory for the daily play history logs
+	HistoryRetentionDays int    // Days to keep play history (0 = keep forever)
+	// Smart playlists
+	SmartPlaylists map[string]SmartPlaylist // Rule-based playlists usable as rotation source
+	// Library index
+	LibraryRescanMinutes int // Minutes between rescans of the music directory (negative = only on startup)
+	LibraryPollSeconds   int // Seconds between polls when filesystem events are unavailable (negative = no polling)
+	// Uploads
+	UploadDir   string // Folder of the music directory receiving uploaded songs
+	UploadMaxMB int    // Maximum size of an uploaded song in MB
+	// Artwork
+	ArtworkSize int // Default edge length in pixels of served artwork
 }
 
 var Config *IConfig
@@ -46,6 +119,7 @@ var Config *IConfig
 type JSONConfig struct {
 	Port               int    `json:"port"`
 	Host               string `json:"host"`
+	TrustProxy         bool   `json:"trust_proxy"`
 	Directory          string `json:"directory"`
 	Random             bool   `json:"random"`
 	Debug              bool   `json:"debug"`
@@ -55,6 +129,7 @@ type JSONConfig struct {
 	StandardSampleRate string `json:"standard_sample_rate"`
 	CacheDir           string `json:"cache_dir"`
 	CacheTTLMinutes    int    `json:"cache_ttl_minutes"`
+	CacheMaxMB         int    `json:"cache_max_mb"`
 	// Shoutcast metadata
 	Genre              string `json:"genre"`
 	URL                string `json:"url"`
@@ -64,6 +139,77 @@ type JSONConfig struct {
 	// Authentication
 	Username           string `json:"username"`
 	Password           string `json:"password"`
+	// Dead-air watchdog
+	DeadAirEnabled        bool    `json:"dead_air_enabled"`
+	DeadAirThresholdDb    float64 `json:"dead_air_threshold_db"`
+	DeadAirSilenceSeconds int     `json:"dead_air_silence_seconds"`
+	DeadAirStallSeconds   int     `json:"dead_air_stall_seconds"`
+	DeadAirResumeSeconds  int     `json:"dead_air_resume_seconds"`
+	// Live handover
+	HandoverMode        string `json:"handover_mode"`
+	HandoverFadeMs      int    `json:"handover_fade_ms"`
+	HandoverCrossfadeMs int    `json:"handover_crossfade_ms"`
+	// Live show recording
+	RecordLive           bool   `json:"record_live"`
+	RecordSource         string `json:"record_source"`
+	ArchiveDir           string `json:"archive_dir"`
+	ArchiveRetentionDays int    `json:"archive_retention_days"`
+	// Aircheck
+	AircheckEnabled         bool   `json:"aircheck_enabled"`
+	AircheckDir             string `json:"aircheck_dir"`
+	AircheckRotationMinutes int    `json:"aircheck_rotation_minutes"`
+	AircheckRetentionDays   int    `json:"aircheck_retention_days"`
+	// Programming schedule
+	Schedule []ScheduleSlot `json:"schedule"`
+	// Clock wheels
+	Categories map[string]RotationCategory `json:"categories"`
+	Clocks     []ClockWheelConfig          `json:"clocks"`
+	Clock      string                      `json:"clock"`
+	// Separation rules
+	TrackSeparationHours    int `json:"track_separation_hours"`
+	ArtistSeparationMinutes int `json:"artist_separation_minutes"`
+	AlbumSeparationTracks   int `json:"album_separation_tracks"`
+	// Random playback
+	RandomMode string             `json:"random_mode"`
+	RandomSeed int64              `json:"random_seed"`
+	Weights    map[string]float64 `json:"weights"`
+	DataDir    string             `json:"data_dir"`
+	// Jingles
+	JingleDir          string `json:"jingle_dir"`
+	JingleEveryTracks  int    `json:"jingle_every_tracks"`
+	JingleEveryMinutes int    `json:"jingle_every_minutes"`
+	JingleTitle        string `json:"jingle_title"`
+	// Ad and sponsor spots
+	SpotDir        string        `json:"spot_dir"`
+	Spots          []SpotConfig  `json:"spots"`
+	Breaks         []BreakConfig `json:"breaks"`
+	ProofOfPlayDir string        `json:"proof_of_play_dir"`
+	// Listener requests
+	RequestsEnabled      bool `json:"requests_enabled"`
+	RequestAutoApprove   bool `json:"request_auto_approve"`
+	RequestLimit         *int `json:"request_limit"` // Pointers, so an explicit 0 (unlimited) is told apart from a missing key
+	RequestWindowMinutes int  `json:"request_window_minutes"`
+	RequestRepeatMinutes *int `json:"request_repeat_minutes"`
+	RequestMinGapTracks  int  `json:"request_min_gap_tracks"`
+	RequestMaxOpen       *int `json:"request_max_open"`
+	// Skip votes and ratings
+	SkipVoteEnabled  bool    `json:"skip_vote_enabled"`
+	SkipVoteShare    float64 `json:"skip_vote_share"`
+	SkipVoteMin      int     `json:"skip_vote_min"`
+	DislikeWeighting bool    `json:"dislike_weighting"`
+	// Play history
+	HistoryDir           string `json:"history_dir"`
+	HistoryRetentionDays int    `json:"history_retention_days"`
+	// Smart playlists
+	SmartPlaylists map[string]SmartPlaylist `json:"smart_playlists"`
+	// Library index
+	LibraryRescanMinutes int `json:"library_rescan_minutes"`
+	LibraryPollSeconds   int `json:"library_poll_seconds"`
+	// Uploads
+	UploadDir   string `json:"upload_dir"`
+	UploadMaxMB int    `json:"upload_max_mb"`
+	// Artwork
+	ArtworkSize int `json:"artwork_size"`
 }
 
 // LoadConfigFromFile loads configuration from a local JSON file
@@ -125,6 +271,7 @@ func init() {
 
 	var port int
 	var host string
+	var trustProxy bool = false
 	var random bool
 	var directory string
 	var debug bool
@@ -136,6 +283,7 @@ func init() {
 	var standardSampleRate string = "44100"
 	var cacheDir string = ".cache"
 	var cacheTTLMinutes int = 10
+	var cacheMaxMB int = 0
 	var genre string = ""
 	var url string = ""
 	var notice1 string = ""
@@ -143,6 +291,60 @@ func init() {
 	var metaInterval int = 8192
 	var username string = ""
 	var password string = ""
+	var deadAirEnabled bool = false
+	var deadAirThresholdDb float64 = -50
+	var deadAirSilenceSeconds int = 15
+	var deadAirStallSeconds int = 5
+	var deadAirResumeSeconds int = 3
+	var handoverMode string = "immediate"
+	var handoverFadeMs int = 2000
+	var handoverCrossfadeMs int = 5000
+	var recordLive bool = false
+	var recordSource string = "input"
+	var archiveDir string = "archive"
+	var archiveRetentionDays int = 0
+	var aircheckEnabled bool = false
+	var aircheckDir string = "aircheck"
+	var aircheckRotationMinutes int = 60
+	var aircheckRetentionDays int = 30
+	var schedule []ScheduleSlot
+	var categories = make(map[string]RotationCategory)
+	var clocks []ClockWheelConfig
+	var clock string
+	var trackSeparationHours int = 0
+	var artistSeparationMinutes int = 0
+	var albumSeparationTracks int = 0
+	var randomMode string = "random"
+	var randomSeed int64 = 0
+	var weights = make(map[string]float64)
+	var dataDir string = "data"
+	var jingleDir string = ""
+	var jingleEveryTracks int = 0
+	var jingleEveryMinutes int = 0
+	var jingleTitle string = ""
+	var spotDir string = "spots"
+	var spots []SpotConfig
+	var breaks []BreakConfig
+	var proofOfPlayDir string = "proof-of-play"
+	var requestsEnabled bool = false
+	var requestAutoApprove bool = false
+	var requestLimit int = 3
+	var requestWindowMinutes int = 60
+	var requestRepeatMinutes int = 120
+	var requestMinGapTracks int = 0
+	var requestMaxOpen int = 50
+	var skipVoteEnabled bool = false
+	var skipVoteShare float64 = 0.5
+	var skipVoteMin int = 1
+	var dislikeWeighting bool = false
+	var historyDir string = "history"
+	var historyRetentionDays int = 0
+	var smartPlaylists = make(map[string]SmartPlaylist)
+	var libraryRescanMinutes int = 60
+	var libraryPollSeconds int = 30
+	var uploadDir string = "uploads"
+	var uploadMaxMB int = 100
+	var artworkSize int = 500
 
 	flag.StringVar(&name, "n", "GoStream", "server name")
 	flag.IntVar(&port, "p", 8090, "server port number")
@@ -177,6 +379,9 @@ func init() {
 		if jsonConfig.Host != "" && host == "0.0.0.0" {
 			host = jsonConfig.Host
 		}
+		if jsonConfig.TrustProxy {
+			trustProxy = true
+		}
 		if jsonConfig.Directory != "" {
 			directory = jsonConfig.Directory
 		}
@@ -198,6 +403,9 @@ func init() {
 		if jsonConfig.CacheTTLMinutes != 0 {
 			cacheTTLMinutes = jsonConfig.CacheTTLMinutes
 		}
+		if jsonConfig.CacheMaxMB > 0 {
+			cacheMaxMB = jsonConfig.CacheMaxMB
+		}
 		// Shoutcast metadata
 		if jsonConfig.Genre != "" {
 			genre = jsonConfig.Genre
@@ -220,6 +428,154 @@ func init() {
 		if jsonConfig.Password != "" {
 			password = jsonConfig.Password
 		}
+		// Dead-air watchdog
+		if jsonConfig.DeadAirThresholdDb != 0 {
+			deadAirThresholdDb = jsonConfig.DeadAirThresholdDb
+		}
+		if jsonConfig.DeadAirSilenceSeconds > 0 {
+			deadAirSilenceSeconds = jsonConfig.DeadAirSilenceSeconds
+		}
+		if jsonConfig.DeadAirStallSeconds > 0 {
+			deadAirStallSeconds = jsonConfig.DeadAirStallSeconds
+		}
+		if jsonConfig.DeadAirResumeSeconds > 0 {
+			deadAirResumeSeconds = jsonConfig.DeadAirResumeSeconds
+		}
+		// Live handover
+		if jsonConfig.HandoverMode != "" {
+			handoverMode = strings.ToLower(jsonConfig.HandoverMode)
+		}
+		if jsonConfig.HandoverFadeMs > 0 {
+			handoverFadeMs = jsonConfig.HandoverFadeMs
+		}
+		if jsonConfig.HandoverCrossfadeMs > 0 {
+			handoverCrossfadeMs = jsonConfig.HandoverCrossfadeMs
+		}
+		// Live show recording
+		if jsonConfig.RecordSource != "" {
+			recordSource = strings.ToLower(jsonConfig.RecordSource)
+		}
+		if jsonConfig.ArchiveDir != "" {
+			archiveDir = jsonConfig.ArchiveDir
+		}
+		if jsonConfig.ArchiveRetentionDays > 0 {
+			archiveRetentionDays = jsonConfig.ArchiveRetentionDays
+		}
+		// Aircheck
+		if jsonConfig.AircheckDir != "" {
+			aircheckDir = jsonConfig.AircheckDir
+		}
+		if jsonConfig.AircheckRotationMinutes > 0 {
+			aircheckRotationMinutes = jsonConfig.AircheckRotationMinutes
+		}
+		if jsonConfig.AircheckRetentionDays > 0 {
+			aircheckRetentionDays = jsonConfig.AircheckRetentionDays
+		}
+		// Programming schedule
+		schedule = jsonConfig.Schedule
+		// Clock wheels
+		if jsonConfig.Categories != nil {
+			categories = jsonConfig.Categories
+		}
+		clocks = jsonConfig.Clocks
+		clock = jsonConfig.Clock
+		// Separation rules
+		if jsonConfig.TrackSeparationHours > 0 {
+			trackSeparationHours = jsonConfig.TrackSeparationHours
+		}
+		if jsonConfig.ArtistSeparationMinutes > 0 {
+			artistSeparationMinutes = jsonConfig.ArtistSeparationMinutes
+		}
+		if jsonConfig.AlbumSeparationTracks > 0 {
+			albumSeparationTracks = jsonConfig.AlbumSeparationTracks
+		}
+		// Random playback
+		if jsonConfig.RandomMode != "" {
+			randomMode = strings.ToLower(jsonConfig.RandomMode)
+		}
+		if jsonConfig.RandomSeed != 0 {
+			randomSeed = jsonConfig.RandomSeed
+		}
+		if jsonConfig.Weights != nil {
+			weights = jsonConfig.Weights
+		}
+		if jsonConfig.DataDir != "" {
+			dataDir = jsonConfig.DataDir
+		}
+		// Jingles
+		if jsonConfig.JingleDir != "" {
+			jingleDir = jsonConfig.JingleDir
+		}
+		if jsonConfig.JingleEveryTracks > 0 {
+			jingleEveryTracks = jsonConfig.JingleEveryTracks
+		}
+		if jsonConfig.JingleEveryMinutes > 0 {
+			jingleEveryMinutes = jsonConfig.JingleEveryMinutes
+		}
+		if jsonConfig.JingleTitle != "" {
+			jingleTitle = jsonConfig.JingleTitle
+		}
+		// Ad and sponsor spots
+		if jsonConfig.SpotDir != "" {
+			spotDir = jsonConfig.SpotDir
+		}
+		spots = jsonConfig.Spots
+		breaks = jsonConfig.Breaks
+		if jsonConfig.ProofOfPlayDir != "" {
+			proofOfPlayDir = jsonConfig.ProofOfPlayDir
+		}
+		// Listener requests
+		if jsonConfig.RequestLimit != nil {
+			requestLimit = *jsonConfig.RequestLimit
+		}
+		if jsonConfig.RequestWindowMinutes > 0 {
+			requestWindowMinutes = jsonConfig.RequestWindowMinutes
+		}
+		if jsonConfig.RequestRepeatMinutes != nil {
+			requestRepeatMinutes = *jsonConfig.RequestRepeatMinutes
+		}
+		if jsonConfig.RequestMinGapTracks > 0 {
+			requestMinGapTracks = jsonConfig.RequestMinGapTracks
+		}
+		if jsonConfig.RequestMaxOpen != nil {
+			requestMaxOpen = *jsonConfig.RequestMaxOpen
+		}
+		// Skip votes and ratings
+		if jsonConfig.SkipVoteShare > 0 {
+			skipVoteShare = jsonConfig.SkipVoteShare
+		}
+		if jsonConfig.SkipVoteMin > 0 {
+			skipVoteMin = jsonConfig.SkipVoteMin
+		}
+		// Play history
+		if jsonConfig.HistoryDir != "" {
+			historyDir = jsonConfig.HistoryDir
+		}
+		if jsonConfig.HistoryRetentionDays > 0 {
+			historyRetentionDays = jsonConfig.HistoryRetentionDays
+		}
+		// Smart playlists
+		if jsonConfig.SmartPlaylists != nil {
+			smartPlaylists = jsonConfig.SmartPlaylists
+		}
+		// Library index
+		if jsonConfig.LibraryRescanMinutes != 0 {
+			libraryRescanMinutes = jsonConfig.LibraryRescanMinutes
+		}
+		if jsonConfig.LibraryPollSeconds != 0 {
+			libraryPollSeconds = jsonConfig.LibraryPollSeconds
+		}
+		// Uploads
+		if jsonConfig.UploadDir != "" {
+			uploadDir = jsonConfig.UploadDir
+		}
+		if jsonConfig.UploadMaxMB > 0 {
+			uploadMaxMB = jsonConfig.UploadMaxMB
+		}
+		// Artwork
+		if jsonConfig.ArtworkSize > 0 {
+			artworkSize = jsonConfig.ArtworkSize
+		}
 		
 		// Boolean flags - only override if they're true in config
 		if jsonConfig.Random {
@@ -228,6 +584,66 @@ func init() {
 		if jsonConfig.Debug {
 			debug = true
 		}
+		if jsonConfig.DeadAirEnabled {
+			deadAirEnabled = true
+		}
+		if jsonConfig.RecordLive {
+			recordLive = true
+		}
+		if jsonConfig.AircheckEnabled {
+			aircheckEnabled = true
+		}
+		if jsonConfig.RequestsEnabled {
+			requestsEnabled = true
+		}
+		if jsonConfig.RequestAutoApprove {
+			requestAutoApprove = true
+		}
+		if jsonConfig.SkipVoteEnabled {
+			skipVoteEnabled = true
+		}
+		if jsonConfig.DislikeWeighting {
+			dislikeWeighting = true
+		}
+	}
+
+	if !IsValidHandoverMode(handoverMode) {
+		log.Fatal("Error loading config: handover_mode must be immediate, wait or crossfade")
+	}
+	if recordSource != RecordSourceInput && recordSource != RecordSourceOutput {
+		log.Fatal("Error loading config: record_source must be input or output")
+	}
+	if !IsValidRandomMode(randomMode) {
+		log.Fatal("Error loading config: random_mode must be random, shuffle or weighted")
+	}
+	for path, weight := range weights {
+		if weight < 0 {
+			log.Fatal(fmt.Sprintf("Error loading config: weight for %s must not be negative", path))
+		}
+	}
+	if skipVoteShare > 1 {
+		log.Fatal("Error loading config: skip_vote_share must be between 0 and 1")
+	}
+	if requestLimit < 0 || requestRepeatMinutes < 0 || requestMaxOpen < 0 {
+		log.Fatal("Error loading config: request_limit, request_repeat_minutes and request_max_open must not be negative")
+	}
+	if !filepath.IsLocal(uploadDir) {
+		log.Fatal("Error loading config: upload_dir must be a folder inside the music directory")
+	}
+	if artworkSize > maxArtworkSize {
+		log.Fatal(fmt.Sprintf("Error loading config: artwork_size must be at most %d", maxArtworkSize))
+	}
+	if err := PrepareSchedule(schedule); err != nil {
+		log.Fatal("Error loading config: ", err)
+	}
+	if err := PrepareClocks(categories, clocks, clock, schedule); err != nil {
+		log.Fatal("Error loading config: ", err)
+	}
+	if err := PrepareSmartPlaylists(smartPlaylists); err != nil {
+		log.Fatal("Error loading config: ", err)
+	}
+	if err := PrepareSpots(spots, breaks); err != nil {
+		log.Fatal("Error loading config: ", err)
 	}
 
 	directory, err = filepath.Abs(directory)
@@ -239,6 +655,7 @@ func init() {
 	Config = &IConfig{
 		Port:               port,
 		Host:               host,
+		TrustProxy:         trustProxy,
 		Random:             random,
 		Directory:          directory,
 		Debug:              debug,
@@ -247,6 +664,7 @@ func init() {
 		Version:            conf.CodeVersion,
 		GapMs:              gap,
 		CacheTTLMinutes:    cacheTTLMinutes,
+		CacheMaxMB:         cacheMaxMB,
 		StandardBitrate:    standardBitrate,
 		StandardSampleRate: standardSampleRate,
 		CacheDir:           cacheDir,
@@ -257,7 +675,80 @@ func init() {
 		MetaInterval:       metaInterval,
 		Username:           username,
 		Password:           password,
+
+		DeadAirEnabled:        deadAirEnabled,
+		DeadAirThresholdDb:    deadAirThresholdDb,
+		DeadAirSilenceSeconds: deadAirSilenceSeconds,
+		DeadAirStallSeconds:   deadAirStallSeconds,
+		DeadAirResumeSeconds:  deadAirResumeSeconds,
+
+		HandoverMode:        handoverMode,
+		HandoverFadeMs:      handoverFadeMs,
+		HandoverCrossfadeMs: handoverCrossfadeMs,
+
+		RecordLive:           recordLive,
+		RecordSource:         recordSource,
+		ArchiveDir:           archiveDir,
+		ArchiveRetentionDays: archiveRetentionDays,
+
+		AircheckEnabled:         aircheckEnabled,
+		AircheckDir:             aircheckDir,
+		AircheckRotationMinutes: aircheckRotationMinutes,
+		AircheckRetentionDays:   aircheckRetentionDays,
+
+		Schedule: schedule,
+
+		Categories: categories,
+		Clocks:     clocks,
+		Clock:      clock,
+
+		TrackSeparationHours:    trackSeparationHours,
+		ArtistSeparationMinutes: artistSeparationMinutes,
+		AlbumSeparationTracks:   albumSeparationTracks,
+
+		RandomMode: randomMode,
+		RandomSeed: randomSeed,
+		Weights:    weights,
+		DataDir:    dataDir,
+
+		JingleDir:          jingleDir,
+		JingleEveryTracks:  jingleEveryTracks,
+		JingleEveryMinutes: jingleEveryMinutes,
+		JingleTitle:        jingleTitle,
+
+		SpotDir:        spotDir,
+		Spots:          spots,
+		Breaks:         breaks,
+		ProofOfPlayDir: proofOfPlayDir,
+
+		RequestsEnabled:      requestsEnabled,
+		RequestAutoApprove:   requestAutoApprove,
+		RequestLimit:         requestLimit,
+		RequestWindowMinutes: requestWindowMinutes,
+		RequestRepeatMinutes: requestRepeatMinutes,
+		RequestMinGapTracks:  requestMinGapTracks,
+		RequestMaxOpen:       requestMaxOpen,
+
+		SkipVoteEnabled:  skipVoteEnabled,
+		SkipVoteShare:    skipVoteShare,
+		SkipVoteMin:      skipVoteMin,
+		DislikeWeighting: dislikeWeighting,
+
+		HistoryDir:           historyDir,
+		HistoryRetentionDays: historyRetentionDays,
+
+		SmartPlaylists: smartPlaylists,
+
+		LibraryRescanMinutes: libraryRescanMinutes,
+		LibraryPollSeconds:   libraryPollSeconds,
+
+		UploadDir:   uploadDir,
+		UploadMaxMB: uploadMaxMB,
+
+		ArtworkSize: artworkSize,
 	}
+
+	SeedRandom(randomSeed)
 }
 
 func GetConfig() *IConfig {
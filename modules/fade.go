@@ -0,0 +1,152 @@
+package modules
+
+import (
+	"strconv"
+
+	"github.com/dmulholl/mp3lib"
+)
+
+// Fades are applied without decoding by lowering the Layer III global_gain of each granule.
+// One gain step is 1.5 dB, so fadeMaxSteps steps bring a frame down by 90 dB (inaudible).
+const fadeMaxSteps = 60
+
+// Samples per Layer III frame, used to convert durations to frame counts
+const samplesPerFrame = 1152
+
+// FramesForDuration returns roughly how many frames cover the given duration at the stream sample rate
+func FramesForDuration(ms int) int {
+	if ms <= 0 {
+		return 0
+	}
+	sampleRate := 44100
+	if Config != nil {
+		if configured, err := strconv.Atoi(Config.StandardSampleRate); err == nil && configured > 0 {
+			sampleRate = configured
+		}
+	}
+	frames := ms * sampleRate / samplesPerFrame / 1000
+	if frames < 1 {
+		frames = 1
+	}
+	return frames
+}
+
+// FadeAttenuation returns the gain reduction in steps for a frame at position pos of a fade lasting total frames
+func FadeAttenuation(pos, total int, fadeOut bool) int {
+	if total <= 0 {
+		return 0
+	}
+	if pos > total {
+		pos = total
+	}
+	if fadeOut {
+		return fadeMaxSteps * pos / total
+	}
+	return fadeMaxSteps * (total - pos) / total
+}
+
+// AttenuateFrame lowers the global gain of every granule in a Layer III frame by the given number of steps
+// Frames of other layers are left untouched. CRC protected frames get their checksum recomputed.
+func AttenuateFrame(frame *mp3lib.MP3Frame, steps int) {
+	if steps <= 0 || frame == nil || frame.MPEGLayer != mp3lib.MPEGLayerIII {
+		return
+	}
+
+	raw := frame.RawBytes
+	sideInfoStart := 4
+	if frame.CrcProtection {
+		sideInfoStart = 6
+	}
+
+	channels := 2
+	if frame.ChannelMode == mp3lib.Mono {
+		channels = 1
+	}
+
+	// Bit layout of the side information (ISO 11172-3 / 13818-3)
+	var headerBits, granules, blockBits int
+	if frame.MPEGVersion == mp3lib.MPEGVersion1 {
+		granules = 2
+		blockBits = 59
+		if channels == 1 {
+			headerBits = 18
+		} else {
+			headerBits = 20
+		}
+	} else {
+		granules = 1
+		blockBits = 63
+		if channels == 1 {
+			headerBits = 9
+		} else {
+			headerBits = 10
+		}
+	}
+
+	sideInfoBits := headerBits + granules*channels*blockBits
+	if len(raw) < sideInfoStart+(sideInfoBits+7)/8 {
+		return
+	}
+
+	// global_gain follows part2_3_length (12 bits) and big_values (9 bits)
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < channels; ch++ {
+			offset := sideInfoStart*8 + headerBits + (gr*channels+ch)*blockBits + 21
+			gain := readBits(raw, offset, 8)
+			if gain > steps {
+				gain -= steps
+			} else {
+				gain = 0
+			}
+			writeBits(raw, offset, 8, gain)
+		}
+	}
+
+	if frame.CrcProtection {
+		crc := frameCRC(raw[2:4], raw[6:sideInfoStart+(sideInfoBits+7)/8])
+		raw[4] = byte(crc >> 8)
+		raw[5] = byte(crc)
+	}
+}
+
+func readBits(data []byte, offset, count int) int {
+	value := 0
+	for i := 0; i < count; i++ {
+		bit := offset + i
+		value <<= 1
+		if data[bit/8]&(0x80>>(bit%8)) != 0 {
+			value |= 1
+		}
+	}
+	return value
+}
+
+func writeBits(data []byte, offset, count, value int) {
+	for i := 0; i < count; i++ {
+		bit := offset + i
+		mask := byte(0x80 >> (bit % 8))
+		if value&(1<<(count-1-i)) != 0 {
+			data[bit/8] |= mask
+		} else {
+			data[bit/8] &^= mask
+		}
+	}
+}
+
+// frameCRC computes the MPEG audio CRC-16 over the last two header bytes and the side information
+func frameCRC(parts ...[]byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, part := range parts {
+		for _, b := range part {
+			for i := 7; i >= 0; i-- {
+				bit := (b >> i) & 1
+				top := byte(crc>>15) & 1
+				crc <<= 1
+				if bit^top == 1 {
+					crc ^= 0x8005
+				}
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,306 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dmulholl/mp3lib"
+)
+
+// Handover modes for switching between automation and a live source
+const (
+	HandoverImmediate = "immediate" // Fade the current track out quickly and go live
+	HandoverWait      = "wait"      // Let the current track finish before going live
+	HandoverCrossfade = "crossfade" // Mix the end of the current track into the start of the live source
+)
+
+// IsValidHandoverMode reports whether mode is a known handover mode
+func IsValidHandoverMode(mode string) bool {
+	return mode == HandoverImmediate || mode == HandoverWait || mode == HandoverCrossfade
+}
+
+// readerHandover holds the fade and handover state of the file reader
+// It has its own lock so frame reading never contends with IMusicReader.Lock
+type readerHandover struct {
+	mu sync.Mutex
+
+	fadeTotal int  // Length of the running fade in frames (0 = no fade)
+	fadePos   int  // Frames of the running fade already played
+	fadeOut   bool // Direction of the running fade
+
+	fadeInNext int // Fade-in length applied to the next track that loads
+
+	pending      bool          // A handover to live is in progress
+	atTrackEnd   bool          // Hand over when the current track ends
+	captureAfter int           // Frames to keep playing before capturing the crossfade tail
+	captureLen   int           // Frames captured for the crossfade tail
+	tail         []byte        // Captured audio mixed into the live source
+	done         chan struct{} // Closed once automation has released the air
+	held         bool          // Automation is paused waiting for live mode
+}
+
+// HandOverToLive starts releasing the air to a live source using the given mode
+// The returned channel is closed once the live source may take over
+func (musicReader *IMusicReader) HandOverToLive(mode string) <-chan struct{} {
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending = true
+	h.held = false
+	h.atTrackEnd = false
+	h.captureAfter = 0
+	h.captureLen = 0
+	h.tail = nil
+	h.fadeInNext = 0
+	h.done = make(chan struct{})
+
+	// Nothing playing - nothing to hand over
+	if musicReader.NoFile() {
+		h.finishLocked()
+		return h.done
+	}
+
+	switch mode {
+	case HandoverWait:
+		h.atTrackEnd = true
+		Logger.Info("Handover: waiting for the current track to end before going live")
+	case HandoverCrossfade:
+		frames := FramesForDuration(Config.HandoverCrossfadeMs)
+		h.captureAfter = frames
+		h.captureLen = frames
+		Logger.Info(fmt.Sprintf("Handover: crossfading into live over %dms", Config.HandoverCrossfadeMs))
+	default:
+		h.startFadeLocked(FramesForDuration(Config.HandoverFadeMs), true)
+		Logger.Info(fmt.Sprintf("Handover: fading out the current track over %dms", Config.HandoverFadeMs))
+	}
+	return h.done
+}
+
+// CancelHandover aborts a pending handover (e.g. the source left before going live)
+// A running fade-out turns into a fade-in from the current level. If automation
+// already released the air, it resumes as after a live session.
+func (musicReader *IMusicReader) CancelHandover() {
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.pending {
+		if h.held {
+			h.resumeLocked(Config.HandoverMode)
+			Logger.Info("Handover to live cancelled after automation stopped - resuming automation")
+		}
+		return
+	}
+	if h.fadeTotal > 0 && h.fadeOut {
+		h.fadePos = h.fadeTotal - h.fadePos
+		h.fadeOut = false
+	}
+	h.pending = false
+	h.atTrackEnd = false
+	h.captureAfter = 0
+	h.tail = nil
+	h.held = false
+	Logger.Info("Handover to live cancelled - staying on automation")
+}
+
+// ResumeFromLive returns the air to automation after a live session
+// The next track fades in according to the mode, mirroring the handover
+func (musicReader *IMusicReader) ResumeFromLive(mode string) {
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resumeLocked(mode)
+}
+
+// resumeLocked releases held automation and queues the fade-in of the next track
+func (h *readerHandover) resumeLocked(mode string) {
+	h.pending = false
+	h.held = false
+	h.tail = nil
+	switch mode {
+	case HandoverWait:
+		h.fadeInNext = 0
+	case HandoverCrossfade:
+		h.fadeInNext = FramesForDuration(Config.HandoverCrossfadeMs)
+	default:
+		h.fadeInNext = FramesForDuration(Config.HandoverFadeMs)
+	}
+}
+
+// IsHeld reports whether automation is paused after handing the air to a live source
+func (musicReader *IMusicReader) IsHeld() bool {
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.held
+}
+
+// TakeHandoverTail returns the audio captured for a crossfade and clears it
+func (musicReader *IMusicReader) TakeHandoverTail() []byte {
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tail := h.tail
+	h.tail = nil
+	return tail
+}
+
+func (h *readerHandover) startFadeLocked(frames int, fadeOut bool) {
+	h.fadeTotal = frames
+	h.fadePos = 0
+	h.fadeOut = fadeOut
+}
+
+// finishLocked marks the handover complete and holds automation until it resumes
+func (h *readerHandover) finishLocked() {
+	if !h.pending {
+		return
+	}
+	h.pending = false
+	h.atTrackEnd = false
+	h.captureAfter = 0
+	h.fadeTotal = 0
+	h.held = true
+	close(h.done)
+}
+
+// startPendingFadeIn applies a queued fade-in to the track that was just loaded
+func (musicReader *IMusicReader) startPendingFadeIn() {
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.fadeInNext > 0 {
+		h.startFadeLocked(h.fadeInNext, false)
+		h.fadeInNext = 0
+	}
+}
+
+// nextFrame reads the next frame of the current track and applies any running fade or handover
+func (musicReader *IMusicReader) nextFrame() *mp3lib.MP3Frame {
+	frame := mp3lib.NextFrame(musicReader.File)
+
+	h := &musicReader.handover
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if frame == nil {
+		// Track ended - in wait mode this is the moment to go live, otherwise the track ran out early
+		if h.pending {
+			musicReader.CloseFile()
+			h.finishLocked()
+		}
+		return nil
+	}
+
+	if h.fadeTotal > 0 {
+		h.fadePos++
+		AttenuateFrame(frame, FadeAttenuation(h.fadePos, h.fadeTotal, h.fadeOut))
+		if h.fadePos >= h.fadeTotal {
+			fadeOut := h.fadeOut
+			h.fadeTotal = 0
+			if fadeOut && h.pending {
+				musicReader.CloseFile()
+				h.finishLocked()
+			}
+		}
+		return frame
+	}
+
+	if h.pending && h.captureAfter > 0 {
+		h.captureAfter--
+		if h.captureAfter == 0 {
+			// Capture the upcoming audio for mixing instead of playing it
+			for i := 0; i < h.captureLen; i++ {
+				tailFrame := mp3lib.NextFrame(musicReader.File)
+				if tailFrame == nil {
+					break
+				}
+				h.tail = append(h.tail, tailFrame.RawBytes...)
+			}
+			musicReader.CloseFile()
+			h.finishLocked()
+		}
+	}
+
+	return frame
+}
+
+// CrossfadeAudio mixes the end of the automation (tail) into the start of the live source (head) with FFmpeg
+// Returns nil if the mix could not be rendered
+func CrossfadeAudio(tail, head []byte, durationMs int) []byte {
+	if len(tail) == 0 || len(head) == 0 {
+		return nil
+	}
+
+	ffmpegPath, err := GetFFmpegPath()
+	if err != nil {
+		Logger.Error(fmt.Sprintf("FFmpeg not found, crossfade disabled: %v", err))
+		return nil
+	}
+
+	if err := os.MkdirAll(Config.CacheDir, 0755); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create cache directory: %v", err))
+		return nil
+	}
+
+	stamp := time.Now().UnixNano()
+	tailPath := filepath.Join(Config.CacheDir, fmt.Sprintf("handover-tail-%d.mp3", stamp))
+	headPath := filepath.Join(Config.CacheDir, fmt.Sprintf("handover-head-%d.mp3", stamp))
+	defer os.Remove(tailPath)
+	defer os.Remove(headPath)
+
+	if err := os.WriteFile(tailPath, tail, 0644); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to write crossfade tail: %v", err))
+		return nil
+	}
+	if err := os.WriteFile(headPath, head, 0644); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to write crossfade head: %v", err))
+		return nil
+	}
+
+	// Keep the overlap a bit shorter than the captured audio so both inputs cover it
+	overlap := float64(durationMs) * 0.8 / 1000
+
+	cmd := exec.Command(ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", tailPath,
+		"-i", headPath,
+		"-filter_complex", fmt.Sprintf("[0:a][1:a]acrossfade=d=%.2f:c1=tri:c2=tri", overlap),
+		"-f", "mp3",
+		"-acodec", "libmp3lame",
+		"-b:a", Config.StandardBitrate,
+		"-ar", Config.StandardSampleRate,
+		"pipe:1",
+	)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			Logger.Error(fmt.Sprintf("Crossfade rendering failed: %v", err))
+			return nil
+		}
+	case <-time.After(5 * time.Second):
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		Logger.Error("Crossfade rendering timed out")
+		return nil
+	}
+
+	return stdout.Bytes()
+}
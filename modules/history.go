@@ -0,0 +1,241 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var historyLogPattern = regexp.MustCompile(`^history-([0-9]{8})\.jsonl$`)
+
+// HistoryEntry is an item that was on air
+type HistoryEntry struct {
+	Type          string `json:"type"`
+	Hash          string `json:"hash,omitempty"`
+	Title         string `json:"title"`
+	Artist        string `json:"artist,omitempty"`
+	Filename      string `json:"filename,omitempty"`
+	Start         int64  `json:"start"`         // Unix milliseconds
+	End           int64  `json:"end,omitempty"` // Unix milliseconds; 0 while still on air
+	PeakListeners int64  `json:"peak_listeners"`
+}
+
+// IHistory records every aired item with its start and end time.
+// Items are written to a daily log when the next item replaces them;
+// an item on air when the station stops is not recorded.
+type IHistory struct {
+	mu      sync.Mutex
+	current *HistoryEntry
+	peak    int64 // Peak listeners of the current item, updated atomically
+}
+
+var History = &IHistory{}
+
+func init() {
+	AddOnAirListener(History.onAir)
+}
+
+// noteListeners raises the peak listener count of the current item
+func (h *IHistory) noteListeners(count int64) {
+	for {
+		peak := atomic.LoadInt64(&h.peak)
+		if count <= peak || atomic.CompareAndSwapInt64(&h.peak, peak, count) {
+			return
+		}
+	}
+}
+
+// onAir closes the previous item and starts a new entry
+func (h *IHistory) onAir(item OnAirItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current != nil {
+		finished := *h.current
+		finished.End = item.Time
+		finished.PeakListeners = atomic.LoadInt64(&h.peak)
+		h.appendLog(finished)
+	}
+
+	listeners := ActiveListenerCount()
+	atomic.StoreInt64(&h.peak, listeners)
+	h.current = &HistoryEntry{
+		Type:     item.Type,
+		Hash:     item.Hash,
+		Title:    item.Title,
+		Artist:   item.Artist,
+		Filename: item.Filename,
+		Start:    item.Time,
+	}
+}
+
+// appendLog adds a finished entry to the daily history log of the day it started
+func (h *IHistory) appendLog(entry HistoryEntry) {
+	if err := os.MkdirAll(Config.HistoryDir, 0755); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create history directory: %v", err))
+		return
+	}
+
+	day := time.UnixMilli(entry.Start).Format("20060102")
+	path := filepath.Join(Config.HistoryDir, fmt.Sprintf("history-%s.jsonl", day))
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Failed to open history log: %v", err))
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to write history log: %v", err))
+	}
+}
+
+// Current returns the item on air as an open history entry
+func (h *IHistory) Current() (HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.current == nil {
+		return HistoryEntry{}, false
+	}
+	entry := *h.current
+	entry.PeakListeners = atomic.LoadInt64(&h.peak)
+	return entry, true
+}
+
+// HistoryEntries returns the entries on air during [from, to), newest first.
+// types limits the result to the given item types (empty = all types).
+func HistoryEntries(from, to time.Time, types map[string]bool) ([]HistoryEntry, error) {
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+	overlaps := func(entry HistoryEntry) bool {
+		if len(types) > 0 && !types[entry.Type] {
+			return false
+		}
+		end := entry.End
+		if end == 0 {
+			end = time.Now().UnixMilli()
+		}
+		return entry.Start < toMs && end > fromMs
+	}
+
+	entries := []HistoryEntry{}
+
+	// Only the daily logs that exist are read. Entries are logged by start day;
+	// start a day early to catch items running past midnight.
+	logs, err := os.ReadDir(Config.HistoryDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, -1).Format("20060102")
+	last := to.Format("20060102")
+	for _, logFile := range logs {
+		match := historyLogPattern.FindStringSubmatch(logFile.Name())
+		if match == nil || match[1] < first || match[1] > last {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(Config.HistoryDir, logFile.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue
+			}
+			if overlaps(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		file.Close()
+	}
+
+	if current, ok := History.Current(); ok && overlaps(current) {
+		entries = append(entries, current)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start > entries[j].Start
+	})
+	return entries, nil
+}
+
+// CleanOldHistory deletes daily history logs older than the retention period
+func CleanOldHistory() error {
+	if Config.HistoryRetentionDays <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(Config.HistoryDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -Config.HistoryRetentionDays)
+	deleted := 0
+	for _, entry := range entries {
+		match := historyLogPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		day, err := time.ParseInLocation("20060102", match[1], time.Local)
+		if err != nil || !day.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(Config.HistoryDir, entry.Name())); err != nil {
+			Logger.Error(fmt.Sprintf("Failed to delete history log %s: %v", entry.Name(), err))
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		Logger.Info(fmt.Sprintf("History cleanup: deleted %d logs older than %d days", deleted, Config.HistoryRetentionDays))
+	}
+	return nil
+}
+
+// StartHistoryCleanupRoutine periodically applies the history retention
+func StartHistoryCleanupRoutine() {
+	if Config.HistoryRetentionDays <= 0 {
+		return
+	}
+
+	go func() {
+		if err := CleanOldHistory(); err != nil {
+			Logger.Error(fmt.Sprintf("Initial history cleanup failed: %v", err))
+		}
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := CleanOldHistory(); err != nil {
+				Logger.Error(fmt.Sprintf("Scheduled history cleanup failed: %v", err))
+			}
+		}
+	}()
+}
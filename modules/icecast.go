@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"net"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -113,6 +114,7 @@ type IcecastSourceServer struct {
 	mu                sync.RWMutex
 	currentSourceConn net.Conn
 	sourceMetadata    map[string]string
+	liveTitle         string // Latest title sent by the source via /admin/metadata
 
 	// Listener tracking
 	listeners      map[string]chan []byte // Map of listener ID to channel
@@ -122,6 +124,7 @@ type IcecastSourceServer struct {
 	// Statistics
 	bytesReceived int64
 	bytesSent     int64
+	lastDataTime  time.Time // When the current source last delivered audio
 }
 
 // IcecastSourceInstance is the singleton instance
@@ -230,6 +233,7 @@ func (s *IcecastSourceServer) handleSource(conn net.Conn) {
 	// Read HTTP-like headers from source client (like Icecast does)
 	headers := make(map[string]string)
 	contentType := ""
+	requestLine := ""
 
 	for {
 		line, err := reader.ReadString('\n')
@@ -240,6 +244,12 @@ func (s *IcecastSourceServer) handleSource(conn net.Conn) {
 
 		line = strings.TrimSpace(line)
 
+		// First line is the request line (e.g. "SOURCE /live HTTP/1.0")
+		if requestLine == "" {
+			requestLine = line
+			continue
+		}
+
 		// Empty line marks end of headers
 		if line == "" {
 			break
@@ -258,6 +268,12 @@ func (s *IcecastSourceServer) handleSource(conn net.Conn) {
 		}
 	}
 
+	// Metadata updates arrive on a separate connection while the source is streaming
+	if strings.HasPrefix(requestLine, "GET ") && strings.Contains(requestLine, "/admin/metadata") {
+		s.handleMetadataUpdate(conn, requestLine)
+		return
+	}
+
 	// Validate content-type
 	if contentType == "" {
 		Logger.Info(fmt.Sprintf("Icecast source from %s missing content-type, rejecting", remoteAddr))
@@ -276,6 +292,8 @@ func (s *IcecastSourceServer) handleSource(conn net.Conn) {
 	s.mu.Lock()
 	s.sourceMetadata = headers
 	s.currentSourceConn = conn
+	s.lastDataTime = time.Now()
+	s.liveTitle = ""
 
 	// Clear the audio buffer to remove stale data
 	if s.audioBuffer != nil {
@@ -313,10 +331,59 @@ func (s *IcecastSourceServer) handleSource(conn net.Conn) {
 
 		s.mu.Lock()
 		s.bytesReceived += int64(n)
+		s.lastDataTime = time.Now()
 		s.mu.Unlock()
 	}
 }
 
+// handleMetadataUpdate processes an Icecast "GET /admin/metadata?mode=updinfo&song=..." request
+func (s *IcecastSourceServer) handleMetadataUpdate(conn net.Conn, requestLine string) {
+	parts := strings.Fields(requestLine)
+	if len(parts) < 2 {
+		conn.Write([]byte("HTTP/1.0 400 Bad Request\r\nContent-Length: 11\r\n\r\nBad Request"))
+		return
+	}
+
+	requestURL, err := url.Parse(parts[1])
+	if err != nil || requestURL.Query().Get("mode") != "updinfo" {
+		conn.Write([]byte("HTTP/1.0 400 Bad Request\r\nContent-Length: 11\r\n\r\nBad Request"))
+		return
+	}
+
+	song := strings.TrimSpace(requestURL.Query().Get("song"))
+
+	s.mu.Lock()
+	s.liveTitle = song
+	s.mu.Unlock()
+
+	Logger.Info(fmt.Sprintf("Icecast source metadata updated: %s", song))
+	LiveRecorder.AddMetadata(song)
+
+	MusicReader.Lock.RLock()
+	isLive := MusicReader.IsIcecastMode
+	MusicReader.Lock.RUnlock()
+	if isLive {
+		AnnounceLive()
+	}
+
+	body := "<?xml version=\"1.0\"?>\n<iceresponse><message>Metadata update successful</message><return>1</return></iceresponse>\n"
+	conn.Write([]byte(fmt.Sprintf("HTTP/1.0 200 OK\r\nContent-Type: text/xml\r\nContent-Length: %d\r\n\r\n%s", len(body), body)))
+}
+
+// LiveTitle returns the latest title sent by the source, falling back to the stream name
+func (s *IcecastSourceServer) LiveTitle() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.liveTitle != "" {
+		return s.liveTitle
+	}
+	if name := s.sourceMetadata["ice-name"]; name != "" {
+		return name
+	}
+	return s.sourceMetadata["icy-name"]
+}
+
 // AddListener registers a new listener and returns a channel for audio data
 func (s *IcecastSourceServer) AddListener() (string, chan []byte) {
 	s.listenersMu.Lock()
@@ -375,6 +442,14 @@ func (s *IcecastSourceServer) HasActiveSource() bool {
 	return s.currentSourceConn != nil
 }
 
+// LastDataTime returns when the current source last delivered audio data
+// A connected source that stops sending is considered stalled by the dead-air watchdog
+func (s *IcecastSourceServer) LastDataTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastDataTime
+}
+
 // GetSourceMetadata returns the metadata from the current source
 func (s *IcecastSourceServer) GetSourceMetadata() map[string]string {
 	s.mu.RLock()
@@ -388,6 +463,13 @@ func (s *IcecastSourceServer) GetSourceMetadata() map[string]string {
 	return meta
 }
 
+// ContentType returns the content type announced by the current source
+func (s *IcecastSourceServer) ContentType() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return strings.ToLower(s.sourceMetadata["content-type"])
+}
+
 // BufferSize returns the current number of bytes in the audio buffer
 func (s *IcecastSourceServer) BufferSize() int {
 	return s.audioBuffer.Size()
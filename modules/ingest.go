@@ -0,0 +1,310 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmulholl/mp3lib"
+)
+
+// Ingest states of an uploaded song
+const (
+	IngestTranscoding = "transcoding"
+	IngestAnalyzing   = "analyzing"
+	IngestReady       = "ready"
+	IngestFailed      = "failed"
+)
+
+// Number of ingest jobs kept for polling
+const maxIngestJobs = 200
+
+// Minimum number of MP3 frames an upload must contain
+const minUploadFrames = 10
+
+// Loudness is the EBU R128 measurement of a song
+type Loudness struct {
+	Integrated float64 `json:"integrated"` // LUFS
+	TruePeak   float64 `json:"true_peak"`  // dBTP
+	Range      float64 `json:"range"`      // LU
+	Threshold  float64 `json:"threshold"`  // LUFS
+}
+
+// IngestJob is the background processing of an uploaded song
+type IngestJob struct {
+	Hash       string    `json:"hash"`
+	Path       string    `json:"path"` // Relative to the music directory
+	Status     string    `json:"status"`
+	Transcoded bool      `json:"transcoded"`
+	Loudness   *Loudness `json:"loudness,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Created    int64     `json:"created"` // Unix milliseconds
+	Updated    int64     `json:"updated"` // Unix milliseconds
+}
+
+// IIngest tracks uploaded songs while they are pre-transcoded and analyzed
+type IIngest struct {
+	mu   sync.Mutex
+	jobs []*IngestJob // Oldest first
+}
+
+var Ingest = &IIngest{}
+
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N} ._()&'-]+`)
+
+// ErrNotMP3 is returned by ValidateMP3 for files that don't hold MPEG audio
+var ErrNotMP3 = errors.New("not an MP3 file")
+
+// ValidateMP3 checks that a file holds MPEG audio by parsing its frames.
+// Returns the number of frames found.
+func ValidateMP3(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	frames := 0
+	frameBytes, tagBytes := int64(0), int64(0)
+	for {
+		obj := mp3lib.NextObject(file)
+		if obj == nil {
+			break
+		}
+		switch object := obj.(type) {
+		case *mp3lib.MP3Frame:
+			frames++
+			frameBytes += int64(object.FrameLength)
+		case *mp3lib.ID3v2Tag:
+			tagBytes += int64(len(object.RawBytes))
+		case *mp3lib.ID3v1Tag:
+			tagBytes += int64(len(object.RawBytes))
+		}
+	}
+
+	// Frame headers can appear by chance in other data; real audio is mostly frames
+	if frames < minUploadFrames || frameBytes*2 < info.Size()-tagBytes {
+		return frames, ErrNotMP3
+	}
+	return frames, nil
+}
+
+// ErrUploadTooLarge is returned for uploads above upload_max_mb
+var ErrUploadTooLarge = errors.New("file too large")
+
+// uploadDir returns the folder of the music directory receiving uploads
+func uploadDir() string {
+	return filepath.Join(Config.Directory, Config.UploadDir)
+}
+
+// uploadPath returns a free path in the upload folder for a file name
+func uploadPath(name string) string {
+	base := strings.TrimSuffix(filepath.Base(strings.ReplaceAll(name, "\\", "/")), filepath.Ext(name))
+	base = strings.TrimSpace(unsafeFileChars.ReplaceAllString(base, "_"))
+	base = strings.TrimLeft(base, ".")
+	if base == "" {
+		base = "upload"
+	}
+
+	path := filepath.Join(uploadDir(), base+".mp3")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(uploadDir(), fmt.Sprintf("%s (%d).mp3", base, i))
+	}
+}
+
+// IngestUpload stores an uploaded file, validates it and adds it to the library.
+// Pre-transcoding and loudness analysis continue in the background.
+// Rejected files return ErrNotMP3 or ErrUploadTooLarge; other errors are server-side.
+func (i *IIngest) IngestUpload(data io.Reader, name string) (IngestJob, error) {
+	if err := os.MkdirAll(uploadDir(), 0755); err != nil {
+		return IngestJob{}, err
+	}
+
+	// The partial file is hidden from the library until it is complete and valid
+	temp, err := os.CreateTemp(uploadDir(), ".upload-*.part")
+	if err != nil {
+		return IngestJob{}, err
+	}
+	tempPath := temp.Name()
+	defer os.Remove(tempPath)
+
+	if err := temp.Chmod(0644); err != nil {
+		Logger.Error(err)
+	}
+
+	limit := int64(Config.UploadMaxMB) * 1024 * 1024
+	written, err := io.Copy(temp, io.LimitReader(data, limit+1))
+	temp.Close()
+	if err != nil {
+		return IngestJob{}, err
+	}
+	if written > limit {
+		return IngestJob{}, ErrUploadTooLarge
+	}
+	if _, err := ValidateMP3(tempPath); err != nil {
+		return IngestJob{}, err
+	}
+
+	// Picking the name and moving the file must not interleave with another upload
+	i.mu.Lock()
+	path := uploadPath(name)
+	err = os.Rename(tempPath, path)
+	i.mu.Unlock()
+	if err != nil {
+		return IngestJob{}, err
+	}
+	if _, err := Library.Update([]string{path}); err != nil {
+		// The upload failed, so the file doesn't stay in the music directory either
+		if removeErr := os.Remove(path); removeErr != nil {
+			Logger.Error(fmt.Sprintf("Failed to remove upload %s: %v", path, removeErr))
+		}
+		return IngestJob{}, err
+	}
+
+	relative, _ := filepath.Rel(Config.Directory, path)
+	now := time.Now().UnixMilli()
+	job := &IngestJob{
+		Hash:    Library.HashForPath(path),
+		Path:    filepath.ToSlash(relative),
+		Status:  IngestTranscoding,
+		Created: now,
+		Updated: now,
+	}
+
+	i.mu.Lock()
+	i.jobs = append(i.jobs, job)
+	if len(i.jobs) > maxIngestJobs {
+		i.jobs = i.jobs[len(i.jobs)-maxIngestJobs:]
+	}
+	snapshot := *job
+	i.mu.Unlock()
+
+	Logger.Info(fmt.Sprintf("Uploaded %s", job.Path))
+	go i.process(job, path)
+	return snapshot, nil
+}
+
+// process pre-transcodes and analyzes an uploaded song
+func (i *IIngest) process(job *IngestJob, path string) {
+	update := func(apply func()) {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		apply()
+		job.Updated = time.Now().UnixMilli()
+	}
+
+	if _, err := GetFFmpegPath(); err != nil {
+		update(func() {
+			job.Status = IngestFailed
+			job.Error = err.Error()
+		})
+		return
+	}
+
+	transcoded, err := transcodeAudio(path, false)
+	update(func() {
+		job.Transcoded = err == nil && transcoded != path
+		job.Status = IngestAnalyzing
+	})
+
+	loudness, err := AnalyzeLoudness(path)
+	update(func() {
+		if err != nil {
+			job.Status = IngestFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Loudness = &loudness
+		job.Status = IngestReady
+	})
+	if err == nil {
+		Logger.Info(fmt.Sprintf("Ingested %s (%.1f LUFS)", job.Path, loudness.Integrated))
+	}
+}
+
+// Job returns the latest ingest job of a song
+func (i *IIngest) Job(hash string) (IngestJob, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for j := len(i.jobs) - 1; j >= 0; j-- {
+		if i.jobs[j].Hash == hash {
+			return *i.jobs[j], true
+		}
+	}
+	return IngestJob{}, false
+}
+
+// Jobs returns the ingest jobs, newest first
+func (i *IIngest) Jobs() []IngestJob {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	jobs := make([]IngestJob, 0, len(i.jobs))
+	for j := len(i.jobs) - 1; j >= 0; j-- {
+		jobs = append(jobs, *i.jobs[j])
+	}
+	return jobs
+}
+
+// AnalyzeLoudness measures the EBU R128 loudness of a file with the loudnorm filter
+func AnalyzeLoudness(path string) (Loudness, error) {
+	ffmpegPath, err := GetFFmpegPath()
+	if err != nil {
+		return Loudness{}, err
+	}
+
+	cmd := exec.Command(ffmpegPath,
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-af", "loudnorm=I=-23:TP=-1.5:LRA=11:print_format=json",
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Loudness{}, fmt.Errorf("loudness analysis failed: %v", err)
+	}
+
+	// The measurement is the last JSON object ffmpeg prints
+	output := stderr.Bytes()
+	start := bytes.LastIndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if start < 0 || end < start {
+		return Loudness{}, fmt.Errorf("loudness analysis printed no measurement")
+	}
+	var measured struct {
+		InputI      string `json:"input_i"`
+		InputTP     string `json:"input_tp"`
+		InputLRA    string `json:"input_lra"`
+		InputThresh string `json:"input_thresh"`
+	}
+	if err := json.Unmarshal(output[start:end+1], &measured); err != nil {
+		return Loudness{}, fmt.Errorf("loudness analysis: %v", err)
+	}
+
+	loudness := Loudness{}
+	fmt.Sscanf(measured.InputI, "%g", &loudness.Integrated)
+	fmt.Sscanf(measured.InputTP, "%g", &loudness.TruePeak)
+	fmt.Sscanf(measured.InputLRA, "%g", &loudness.Range)
+	fmt.Sscanf(measured.InputThresh, "%g", &loudness.Threshold)
+	return loudness, nil
+}
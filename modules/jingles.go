@@ -0,0 +1,174 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IJingles inserts station IDs and jingles between songs.
+// Jingles live outside the song library: they never get a song hash and never
+// touch CurrentSongHash, CachedNextHash or the playlist.
+type IJingles struct {
+	mu          sync.Mutex
+	tracksSince int       // Songs started since the last jingle
+	lastPlayed  time.Time // When the last jingle (or the station) started
+	rotation    []string  // Jingle paths still to play in this rotation
+	playing     bool      // A jingle is on air
+}
+
+var Jingles = &IJingles{
+	lastPlayed: time.Now(),
+}
+
+// JingleStatus describes the jingle rotation
+type JingleStatus struct {
+	Enabled     bool  `json:"enabled"`
+	Playing     bool  `json:"playing"`
+	TracksSince int   `json:"tracks_since"`
+	LastPlayed  int64 `json:"last_played"` // Unix milliseconds
+}
+
+func jinglesEnabled() bool {
+	return Config.JingleDir != "" && (Config.JingleEveryTracks > 0 || Config.JingleEveryMinutes > 0)
+}
+
+// due reports whether a jingle should play before the next song
+func (j *IJingles) due(now time.Time) bool {
+	if !jinglesEnabled() {
+		return false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Never play two jingles in a row
+	if j.playing {
+		return false
+	}
+	if Config.JingleEveryTracks > 0 && j.tracksSince >= Config.JingleEveryTracks {
+		return true
+	}
+	if Config.JingleEveryMinutes > 0 && now.Sub(j.lastPlayed) >= time.Duration(Config.JingleEveryMinutes)*time.Minute {
+		return true
+	}
+	return false
+}
+
+// next returns the path of the next jingle, going through the library in shuffled order
+func (j *IJingles) next() (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.rotation) == 0 {
+		paths, err := scanMp3Files(Config.JingleDir)
+		if err != nil {
+			return "", err
+		}
+		if len(paths) == 0 {
+			return "", fmt.Errorf("no mp3 files found in %s", Config.JingleDir)
+		}
+		j.rotation = shuffled(paths)
+	}
+
+	path := j.rotation[0]
+	j.rotation = j.rotation[1:]
+	return path, nil
+}
+
+// jingleStarted resets the rules after a jingle went on air
+func (j *IJingles) jingleStarted(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tracksSince = 0
+	j.lastPlayed = now
+	j.playing = true
+}
+
+// songStarted counts a song towards the next jingle
+func (j *IJingles) songStarted() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tracksSince++
+	j.playing = false
+}
+
+// Playing reports whether a jingle is on air
+func (j *IJingles) Playing() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.playing
+}
+
+// Status returns the state of the jingle rotation
+func (j *IJingles) Status() JingleStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JingleStatus{
+		Enabled:     jinglesEnabled(),
+		Playing:     j.playing,
+		TracksSince: j.tracksSince,
+		LastPlayed:  j.lastPlayed.UnixMilli(),
+	}
+}
+
+// playJingleIfDue puts a jingle on air when the rules call for one.
+// Returns false when no jingle is due or none could be opened, so a song plays instead.
+func (musicReader *IMusicReader) playJingleIfDue() bool {
+	now := time.Now()
+	if !Jingles.due(now) {
+		return false
+	}
+	// Keep ad breaks together; the jingle plays after the break
+	if AdBreaks.IsSpot(musicReader.GetCachedNextHash()) {
+		return false
+	}
+
+	jinglePath, err := Jingles.next()
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Jingle skipped: %v", err))
+		return false
+	}
+
+	// Same transcoding path as songs for a consistent stream format
+	filePath := jinglePath
+	if transcodedPath, err := TranscodeAudio(jinglePath); err == nil {
+		filePath = transcodedPath
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Jingle skipped: %v", err))
+		return false
+	}
+	musicReader.File = file
+
+	filename := strings.TrimSuffix(filepath.Base(jinglePath), filepath.Ext(jinglePath))
+	title := Config.JingleTitle
+	if title != "" {
+		// Replace the stream title while the jingle plays; an empty jingle_title keeps the previous song's title
+		info := IMusicInfoStoreData{}
+		if current := musicReader.GetMusicInfoStoreData(); current != nil {
+			info = *current
+		}
+		info.Title = title
+		info.Artist = Config.Name
+		// The ICY StreamTitle is built from Filename
+		info.Filename = title
+		musicReader.SetInfoStoreData(info)
+	}
+
+	Jingles.jingleStarted(now)
+	musicReader.startPendingFadeIn()
+	Logger.Info(fmt.Sprintf("Playing jingle %s", filepath.Base(jinglePath)))
+
+	AnnounceOnAir(OnAirItem{
+		Type:     OnAirJingle,
+		Title:    title,
+		Filename: filename,
+	})
+	return true
+}
@@ -0,0 +1,618 @@
+package modules
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmulholl/mp3lib"
+)
+
+// File in the data directory holding the library index
+const libraryFile = "library.json"
+
+// File in the data directory mapping earlier song hashes to the current ones
+const songAliasesFile = "song_aliases.json"
+
+// LibraryEntry is an indexed song of the music directory.
+// Hash identifies the song by its audio, so it survives renames, moves and tag edits;
+// a copy of a song that is already indexed is identified by its path instead.
+type LibraryEntry struct {
+	Path      string `json:"path"`
+	Hash      string `json:"hash"`
+	AudioHash string `json:"audio_hash"` // MD5 of the MPEG frames without tags
+	TrackMeta
+	Duration   int64 `json:"duration"`    // Milliseconds, estimated for CBR files without a Xing header
+	Bitrate    int   `json:"bitrate"`     // kbps of the first frame
+	SampleRate int   `json:"sample_rate"` // Hz
+	ModTime    int64 `json:"mtime"`       // Unix nanoseconds
+	Size       int64 `json:"size"`
+	FirstSeen  int64 `json:"first_seen"` // Unix milliseconds the song was first indexed
+}
+
+// DisplayTitle returns the title tag or the file name
+func (entry LibraryEntry) DisplayTitle() string {
+	if entry.Title != "" {
+		return entry.Title
+	}
+	return filepath.Base(entry.Path)
+}
+
+// DisplayArtist returns the artist tag or "Unknown"
+func (entry LibraryEntry) DisplayArtist() string {
+	if entry.Artist != "" {
+		return entry.Artist
+	}
+	return "Unknown"
+}
+
+// LibraryScan summarises what a refresh changed
+type LibraryScan struct {
+	Added    int   `json:"added"`
+	Updated  int   `json:"updated"`
+	Removed  int   `json:"removed"`
+	Total    int   `json:"total"`
+	Duration int64 `json:"duration"` // Milliseconds the scan took
+}
+
+// ILibrary indexes the songs of the music directory with their tags and audio properties.
+// The index is saved in the data directory; a refresh only stats the files and
+// reads the ones that are new or whose modification time or size changed.
+type ILibrary struct {
+	mu       sync.RWMutex
+	loaded   bool
+	byPath   map[string]*LibraryEntry
+	byHash   map[string]*LibraryEntry
+	aliases  map[string]string // Earlier hashes (path hashes, replaced hashes) -> current hash
+	sorted   []*LibraryEntry   // By path
+	lastScan time.Time
+
+	scanMu sync.Mutex // Serialises refreshes
+}
+
+var Library = &ILibrary{
+	byPath:  make(map[string]*LibraryEntry),
+	byHash:  make(map[string]*LibraryEntry),
+	aliases: make(map[string]string),
+}
+
+func (l *ILibrary) loadLocked() {
+	if l.loaded {
+		return
+	}
+	l.loaded = true
+
+	var entries []LibraryEntry
+	if err := LoadJSON(libraryFile, &entries); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load library index: %v", err))
+		return
+	}
+	if err := LoadJSON(songAliasesFile, &l.aliases); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load song aliases: %v", err))
+	}
+	if l.aliases == nil {
+		l.aliases = make(map[string]string)
+	}
+
+	// Entries of another music directory are rescanned
+	prefix := filepath.Clean(Config.Directory) + string(filepath.Separator)
+	kept := make([]*LibraryEntry, 0, len(entries))
+	for i := range entries {
+		if strings.HasPrefix(entries[i].Path, prefix) {
+			// Indexes saved before first_seen existed only know the file date
+			if entries[i].FirstSeen == 0 {
+				entries[i].FirstSeen = entries[i].ModTime / int64(time.Millisecond)
+			}
+			kept = append(kept, &entries[i])
+		}
+	}
+	l.setLocked(kept)
+}
+
+// indexChanges lists what an update of the index means for the rest of the player
+type indexChanges struct {
+	removed []string          // Hashes of songs that left the library
+	renamed map[string]string // Old hash -> new hash
+	moved   map[string]string // Old path -> new path of songs that were moved or renamed
+}
+
+// setLocked replaces the index and publishes the song hashes and their aliases to the reader
+func (l *ILibrary) setLocked(entries []*LibraryEntry) indexChanges {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	previous := l.byHash
+	previousPaths := l.byPath
+	renamed := l.assignHashesLocked(entries)
+	l.byPath = make(map[string]*LibraryEntry, len(entries))
+	l.byHash = make(map[string]*LibraryEntry, len(entries))
+	hashes := make([]string, len(entries))
+	for i, entry := range entries {
+		l.byPath[entry.Path] = entry
+		l.byHash[entry.Hash] = entry
+		hashes[i] = entry.Hash
+		SongHashMap.Store(entry.Hash, entry.Path)
+	}
+
+	// Old hashes keep resolving to the song while it is in the library
+	for hash, target := range l.aliases {
+		if next, ok := renamed[target]; ok {
+			l.aliases[hash] = next
+		}
+	}
+	for hash, target := range renamed {
+		l.aliases[hash] = target
+	}
+	for _, entry := range entries {
+		if pathHash := GenerateSongHash(entry.Path); pathHash != entry.Hash {
+			l.aliases[pathHash] = entry.Hash
+		}
+	}
+	for hash, target := range l.aliases {
+		entry, ok := l.byHash[target]
+		if _, current := l.byHash[hash]; current {
+			delete(l.aliases, hash)
+		} else if !ok {
+			delete(l.aliases, hash)
+			SongHashMap.Delete(hash)
+		} else {
+			SongHashMap.Store(hash, entry.Path)
+		}
+	}
+
+	changes := indexChanges{renamed: renamed, moved: make(map[string]string)}
+	for hash := range previous {
+		if _, ok := l.byHash[hash]; !ok {
+			if _, ok := l.aliases[hash]; !ok {
+				SongHashMap.Delete(hash)
+				changes.removed = append(changes.removed, hash)
+			}
+		}
+	}
+	// A song whose path left the library while its hash stayed was moved
+	for path, entry := range previousPaths {
+		if _, ok := l.byPath[path]; ok {
+			continue
+		}
+		hash := entry.Hash
+		if target, ok := l.aliases[hash]; ok {
+			hash = target
+		}
+		if moved, ok := l.byHash[hash]; ok {
+			changes.moved[path] = moved.Path
+			if entry.FirstSeen > 0 && entry.FirstSeen < moved.FirstSeen {
+				moved.FirstSeen = entry.FirstSeen
+			}
+		}
+	}
+	l.sorted = entries
+	SortedSongHashes = hashes
+	return changes
+}
+
+// assignHashesLocked identifies every entry by its audio. A song keeps its hash when
+// another copy of the same audio appears; the copy is identified by its path.
+// Returns the hashes that changed (old -> new).
+func (l *ILibrary) assignHashesLocked(entries []*LibraryEntry) map[string]string {
+	// Re-read entries don't carry the hash they had before
+	current := func(entry *LibraryEntry) string {
+		if entry.Hash != "" {
+			return entry.Hash
+		}
+		if indexed, ok := l.byPath[entry.Path]; ok {
+			return indexed.Hash
+		}
+		return ""
+	}
+
+	taken := make(map[string]bool, len(entries))
+	kept := make(map[*LibraryEntry]bool)
+	for _, entry := range entries {
+		if entry.AudioHash != "" && current(entry) == entry.AudioHash && !taken[entry.AudioHash] {
+			entry.Hash = entry.AudioHash
+			taken[entry.Hash] = true
+			kept[entry] = true
+		}
+	}
+
+	renamed := make(map[string]string)
+	for _, entry := range entries {
+		if kept[entry] {
+			continue
+		}
+		hash := entry.AudioHash
+		if hash == "" || taken[hash] {
+			hash = GenerateSongHash(entry.Path)
+		}
+		if old := current(entry); old != "" && old != hash {
+			renamed[old] = hash
+		}
+		entry.Hash = hash
+		taken[hash] = true
+	}
+	return renamed
+}
+
+func (l *ILibrary) saveLocked() {
+	entries := make([]LibraryEntry, len(l.sorted))
+	for i, entry := range l.sorted {
+		entries[i] = *entry
+	}
+	if err := SaveJSON(libraryFile, entries); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save library index: %v", err))
+	}
+	if err := SaveJSON(songAliasesFile, l.aliases); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save song aliases: %v", err))
+	}
+}
+
+// Refresh rescans the music directory, reading only new and changed files
+func (l *ILibrary) Refresh() (LibraryScan, error) {
+	return l.Update([]string{Config.Directory})
+}
+
+// Update re-indexes files and directories of the music directory after they were
+// created, changed, moved or deleted. Directories are walked; paths that no longer
+// exist are dropped together with everything below them.
+func (l *ILibrary) Update(paths []string) (LibraryScan, error) {
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+
+	started := time.Now()
+	l.mu.Lock()
+	l.loadLocked()
+	next := make(map[string]*LibraryEntry, len(l.byPath))
+	for path, entry := range l.byPath {
+		next[path] = entry
+	}
+	l.mu.Unlock()
+
+	scan := LibraryScan{}
+	for _, path := range paths {
+		if err := indexPath(next, filepath.Clean(path), &scan); err != nil {
+			return scan, err
+		}
+	}
+
+	entries := make([]*LibraryEntry, 0, len(next))
+	for _, entry := range next {
+		entries = append(entries, entry)
+	}
+	changed := scan.Added > 0 || scan.Updated > 0 || scan.Removed > 0
+
+	l.mu.Lock()
+	changes := l.setLocked(entries)
+	l.lastScan = time.Now()
+	if changed || len(changes.renamed) > 0 {
+		l.saveLocked()
+	}
+	l.mu.Unlock()
+	if changed || len(changes.renamed) > 0 {
+		invalidateSmartPlaylists()
+	}
+
+	scan.Total = len(entries)
+	scan.Duration = time.Since(started).Milliseconds()
+	if changed {
+		Logger.Info(fmt.Sprintf("Library scan: %d added, %d updated, %d removed, %d songs (%d ms)", scan.Added, scan.Updated, scan.Removed, scan.Total, scan.Duration))
+	}
+	if len(changes.removed) > 0 {
+		forgetSongs(changes.removed)
+	}
+	if len(changes.renamed) > 0 {
+		Logger.Info(fmt.Sprintf("Library scan: %d songs got a new hash", len(changes.renamed)))
+		renameSongs(changes.renamed)
+	}
+	for oldPath, newPath := range changes.moved {
+		Playlists.renameSong(oldPath, newPath)
+	}
+	return scan, nil
+}
+
+// indexPath brings the entries for path up to date
+func indexPath(entries map[string]*LibraryEntry, path string, scan *LibraryScan) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) && path != filepath.Clean(Config.Directory) {
+		dropPath(entries, path, nil, scan)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		indexFile(entries, path, info, scan)
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && indexFile(entries, file, info, scan) {
+			seen[file] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	dropPath(entries, path, seen, scan)
+	return nil
+}
+
+// indexFile reads an MP3 file when it is new or changed; reports whether it is a song
+func indexFile(entries map[string]*LibraryEntry, path string, info os.FileInfo, scan *LibraryScan) bool {
+	if !strings.HasSuffix(strings.ToLower(info.Name()), ".mp3") {
+		return false
+	}
+
+	// Entries indexed before songs were identified by their audio are read again
+	entry, ok := entries[path]
+	if ok && entry.AudioHash != "" && entry.ModTime == info.ModTime().UnixNano() && entry.Size == info.Size() {
+		return true
+	}
+	fresh := readLibraryEntry(path, info)
+	fresh.FirstSeen = time.Now().UnixMilli()
+	if ok && entry.FirstSeen > 0 {
+		fresh.FirstSeen = entry.FirstSeen
+	}
+	entries[path] = &fresh
+	if ok {
+		scan.Updated++
+	} else {
+		scan.Added++
+	}
+	return true
+}
+
+// dropPath removes path and the entries below it, except the ones in keep
+func dropPath(entries map[string]*LibraryEntry, path string, keep map[string]bool, scan *LibraryScan) {
+	prefix := path + string(filepath.Separator)
+	for file := range entries {
+		if (file == path || strings.HasPrefix(file, prefix)) && !keep[file] {
+			delete(entries, file)
+			scan.Removed++
+		}
+	}
+}
+
+// forgetSongs removes songs that left the library from the queue and from open requests
+func forgetSongs(hashes []string) {
+	removed := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		removed[hash] = true
+	}
+	MusicReader.dropSongs(removed)
+	Requests.dropSongs(removed)
+}
+
+// renameSongs moves the queue, open requests, play counts and ratings of songs to their new hash
+func renameSongs(renamed map[string]string) {
+	for oldHash, newHash := range renamed {
+		MusicReader.renameSong(oldHash, newHash)
+		Requests.renameSong(oldHash, newHash)
+		PlayCounts.renameSong(oldHash, newHash)
+		TrackRatings.renameSong(oldHash, newHash)
+		renameRecentPlays(oldHash, newHash)
+		renameShuffledSong(oldHash, newHash)
+	}
+}
+
+// readLibraryEntry reads the tags and the audio properties of a song and hashes its audio.
+// The hash is assigned when the entry joins the index.
+func readLibraryEntry(path string, info os.FileInfo) LibraryEntry {
+	entry := LibraryEntry{
+		Path:      path,
+		TrackMeta: readID3Tags(path),
+		ModTime:   info.ModTime().UnixNano(),
+		Size:      info.Size(),
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		Logger.Error(err)
+		return entry
+	}
+	defer file.Close()
+
+	// Skip the ID3v2 tags; their length doesn't count towards the audio.
+	// Only frames are hashed, so editing the tags keeps the hash.
+	audio := md5.New()
+	frames := 0
+	tagBytes := int64(0)
+	for {
+		obj := mp3lib.NextObject(file)
+		if obj == nil {
+			break
+		}
+		switch object := obj.(type) {
+		case *mp3lib.ID3v2Tag:
+			tagBytes += int64(len(object.RawBytes))
+		case *mp3lib.MP3Frame:
+			if frames == 0 {
+				entry.Bitrate = object.BitRate / 1000
+				entry.SampleRate = object.SamplingRate
+				entry.Duration = frameDuration(object, info.Size()-tagBytes)
+			}
+			audio.Write(object.RawBytes)
+			frames++
+		}
+	}
+
+	// Files without frames have nothing to identify them by but their path
+	if frames == 0 {
+		entry.AudioHash = GenerateSongHash(path)
+		return entry
+	}
+	entry.AudioHash = hex.EncodeToString(audio.Sum(nil))
+	return entry
+}
+
+// frameDuration returns the duration of a song in milliseconds from its first frame.
+// VBR files carry the frame count in a Xing or VBRI header; otherwise the bitrate is constant.
+func frameDuration(frame *mp3lib.MP3Frame, audioBytes int64) int64 {
+	if frame.SamplingRate <= 0 {
+		return 0
+	}
+	frames := int64(0)
+	raw := frame.RawBytes
+	if mp3lib.IsXingHeader(frame) {
+		at := bytes.Index(raw, []byte("Xing"))
+		if at < 0 {
+			at = bytes.Index(raw, []byte("Info"))
+		}
+		// Flags follow the tag; bit 0 announces the frame count
+		if at >= 0 && len(raw) >= at+12 && binary.BigEndian.Uint32(raw[at+4:at+8])&1 != 0 {
+			frames = int64(binary.BigEndian.Uint32(raw[at+8 : at+12]))
+		}
+	} else if mp3lib.IsVbriHeader(frame) && len(raw) >= 4+32+18 {
+		frames = int64(binary.BigEndian.Uint32(raw[4+32+14 : 4+32+18]))
+	}
+	if frames > 0 {
+		return frames * int64(frame.SampleCount) * 1000 / int64(frame.SamplingRate)
+	}
+
+	if frame.BitRate <= 0 || audioBytes <= 0 {
+		return 0
+	}
+	return audioBytes * 8 * 1000 / int64(frame.BitRate)
+}
+
+// Paths returns the paths of the indexed songs, sorted
+func (l *ILibrary) Paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadLocked()
+
+	paths := make([]string, len(l.sorted))
+	for i, entry := range l.sorted {
+		paths[i] = entry.Path
+	}
+	return paths
+}
+
+// PathsUnder returns the indexed songs in dir, sorted.
+// ok is false when dir is outside the music directory.
+func (l *ILibrary) PathsUnder(dir string) ([]string, bool) {
+	root := filepath.Clean(Config.Directory)
+	dir = filepath.Clean(dir)
+	if dir != root && !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		return nil, false
+	}
+
+	paths := []string{}
+	for _, path := range l.Paths() {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			paths = append(paths, path)
+		}
+	}
+	return paths, true
+}
+
+// Entries returns the indexed songs sorted by path
+func (l *ILibrary) Entries() []LibraryEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadLocked()
+
+	entries := make([]LibraryEntry, len(l.sorted))
+	for i, entry := range l.sorted {
+		entries[i] = *entry
+	}
+	return entries
+}
+
+// Entry returns the indexed song with the given hash or one of its aliases
+func (l *ILibrary) Entry(hash string) (LibraryEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadLocked()
+
+	entry, ok := l.byHash[hash]
+	if !ok {
+		entry, ok = l.byHash[l.aliases[hash]]
+	}
+	if !ok {
+		return LibraryEntry{}, false
+	}
+	return *entry, true
+}
+
+// ResolveHash returns the current hash of a song known by an earlier hash.
+// Other hashes are returned unchanged.
+func (l *ILibrary) ResolveHash(hash string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadLocked()
+
+	if target, ok := l.aliases[hash]; ok {
+		return target
+	}
+	return hash
+}
+
+// HashForPath returns the hash of a song file: its library hash when it is indexed,
+// its path hash otherwise
+func (l *ILibrary) HashForPath(path string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadLocked()
+
+	if entry, ok := l.byPath[filepath.Clean(path)]; ok {
+		return entry.Hash
+	}
+	return GenerateSongHash(path)
+}
+
+// LastScan returns when the music directory was last scanned
+func (l *ILibrary) LastScan() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastScan
+}
+
+// SongTitleArtist returns the display title and artist of a song by hash.
+// Songs outside the library fall back to their (cached) tags.
+func SongTitleArtist(hash string) (string, string) {
+	if entry, ok := Library.Entry(hash); ok {
+		return entry.DisplayTitle(), entry.DisplayArtist()
+	}
+	path, ok := FindSongByHash(hash)
+	if !ok {
+		return "", ""
+	}
+	entry := LibraryEntry{Path: path}
+	entry.TrackMeta, _ = cachedID3Tags(path)
+	return entry.DisplayTitle(), entry.DisplayArtist()
+}
+
+// StartLibraryRefreshRoutine scans the music directory on startup and then periodically
+func StartLibraryRefreshRoutine() {
+	go func() {
+		if _, err := Library.Refresh(); err != nil {
+			Logger.Error(fmt.Sprintf("Initial library scan failed: %v", err))
+		}
+
+		if Config.LibraryRescanMinutes <= 0 {
+			return
+		}
+		ticker := time.NewTicker(time.Duration(Config.LibraryRescanMinutes) * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := Library.Refresh(); err != nil {
+				Logger.Error(fmt.Sprintf("Scheduled library scan failed: %v", err))
+			}
+		}
+	}()
+}
@@ -21,6 +21,7 @@ type MetricsData struct {
 	NumGoroutines     int     `json:"num_goroutines"`
 	CPUUsagePercent   float64 `json:"cpu_usage_percent"`
 	BandwidthMbps     float64 `json:"bandwidth_mbps"`
+	TranscodeCache    TranscodeCacheStats `json:"transcode_cache"`
 }
 
 var (
@@ -39,7 +40,8 @@ var (
 
 // IncrementListener increments the active listener count
 func IncrementListener() {
-	atomic.AddInt64(&metrics.activeListeners, 1)
+	count := atomic.AddInt64(&metrics.activeListeners, 1)
+	History.noteListeners(count)
 }
 
 // DecrementListener decrements the active listener count
@@ -47,6 +49,11 @@ func DecrementListener() {
 	atomic.AddInt64(&metrics.activeListeners, -1)
 }
 
+// ActiveListenerCount returns the number of connected listeners
+func ActiveListenerCount() int64 {
+	return atomic.LoadInt64(&metrics.activeListeners)
+}
+
 // AddBytesStreamed adds bytes to the total streamed count
 func AddBytesStreamed(bytes int64) {
 	atomic.AddInt64(&metrics.totalBytesStreamed, bytes)
@@ -105,6 +112,7 @@ func GetMetrics() MetricsData {
 		GCPauseMs:          gcPauseMs,
 		NumGoroutines:      runtime.NumGoroutine(),
 		BandwidthMbps:      bandwidthMbps,
+		TranscodeCache:     TranscodeCache.Stats(),
 	}
 }
 
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"sync"
+	"time"
+)
+
+// On-air item types
+const (
+	OnAirTrack  = "track"
+	OnAirLive   = "live"
+	OnAirJingle = "jingle"
+	OnAirSpot   = "spot"
+)
+
+// OnAirItem describes what the station is broadcasting
+type OnAirItem struct {
+	Type     string `json:"type"`
+	Hash     string `json:"hash,omitempty"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Time     int64  `json:"time"` // Unix milliseconds when the item went on air
+}
+
+var onAir = struct {
+	mu        sync.RWMutex
+	current   OnAirItem
+	listeners []func(OnAirItem)
+}{}
+
+// AddOnAirListener registers a function called whenever a new item goes on air
+func AddOnAirListener(listener func(OnAirItem)) {
+	onAir.mu.Lock()
+	defer onAir.mu.Unlock()
+	onAir.listeners = append(onAir.listeners, listener)
+}
+
+// CurrentOnAir returns the item currently on air
+func CurrentOnAir() OnAirItem {
+	onAir.mu.RLock()
+	defer onAir.mu.RUnlock()
+	return onAir.current
+}
+
+// AnnounceOnAir records that a new item went on air and notifies listeners
+func AnnounceOnAir(item OnAirItem) {
+	if item.Time == 0 {
+		item.Time = time.Now().UnixMilli()
+	}
+
+	onAir.mu.Lock()
+	onAir.current = item
+	listeners := make([]func(OnAirItem), len(onAir.listeners))
+	copy(listeners, onAir.listeners)
+	onAir.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(item)
+	}
+}
+
+// AnnounceLive announces the live source with its current title
+func AnnounceLive() {
+	AnnounceOnAir(OnAirItem{
+		Type:  OnAirLive,
+		Title: IcecastSource.LiveTitle(),
+	})
+}
@@ -0,0 +1,117 @@
+package modules
+
+import (
+	"fmt"
+	"sync"
+)
+
+// File in the data directory holding the play counts
+const playCountsFile = "play_counts.json"
+
+// PlayCount is how often and when a song was last played
+type PlayCount struct {
+	Count      int   `json:"count"`
+	LastPlayed int64 `json:"last_played"` // Unix milliseconds
+}
+
+// IPlayCounts counts how often every song went on air
+type IPlayCounts struct {
+	mu     sync.Mutex
+	loaded bool
+	counts map[string]*PlayCount
+}
+
+var PlayCounts = &IPlayCounts{
+	counts: make(map[string]*PlayCount),
+}
+
+func init() {
+	AddOnAirListener(PlayCounts.onAir)
+}
+
+func (p *IPlayCounts) loadLocked() {
+	if p.loaded {
+		return
+	}
+	p.loaded = true
+
+	if err := LoadJSON(playCountsFile, &p.counts); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load play counts: %v", err))
+	}
+	if p.counts == nil {
+		p.counts = make(map[string]*PlayCount)
+	}
+}
+
+func (p *IPlayCounts) onAir(item OnAirItem) {
+	if item.Type != OnAirTrack || item.Hash == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	count, ok := p.counts[item.Hash]
+	if !ok {
+		count = &PlayCount{}
+		p.counts[item.Hash] = count
+	}
+	count.Count++
+	count.LastPlayed = item.Time
+	invalidateSmartPlaylists()
+
+	if err := SaveJSON(playCountsFile, p.counts); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save play counts: %v", err))
+	}
+}
+
+// Get returns the play count of a song
+func (p *IPlayCounts) Get(hash string) PlayCount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	if count, ok := p.counts[hash]; ok {
+		return *count
+	}
+	return PlayCount{}
+}
+
+// renameSong moves the play count of a song to its new hash
+func (p *IPlayCounts) renameSong(oldHash, newHash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	count, ok := p.counts[oldHash]
+	if !ok {
+		return
+	}
+	delete(p.counts, oldHash)
+	if existing, ok := p.counts[newHash]; ok {
+		existing.Count += count.Count
+		if count.LastPlayed > existing.LastPlayed {
+			existing.LastPlayed = count.LastPlayed
+		}
+	} else {
+		p.counts[newHash] = count
+	}
+
+	if err := SaveJSON(playCountsFile, p.counts); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save play counts: %v", err))
+	}
+}
+
+// All returns the play counts of all songs that went on air
+func (p *IPlayCounts) All() map[string]PlayCount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	counts := make(map[string]PlayCount, len(p.counts))
+	for hash, count := range p.counts {
+		counts[hash] = *count
+	}
+	return counts
+}
@@ -0,0 +1,640 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File in the data directory holding the named playlists
+const playlistsFile = "playlists.json"
+
+// Prefix of schedule and category sources that refer to a named playlist
+const playlistSourcePrefix = "playlist:"
+
+// Selector priority of the active playlist: replaces the library, but schedule slots and clocks come first
+const SelectorPriorityPlaylist = 50
+
+// Playlist file formats for import and export
+const (
+	PlaylistFormatM3U  = "m3u"
+	PlaylistFormatM3U8 = "m3u8"
+	PlaylistFormatPLS  = "pls"
+)
+
+// NamedPlaylist is a saved list of songs
+type NamedPlaylist struct {
+	Name    string   `json:"name"`
+	Songs   []string `json:"songs"` // Paths relative to the music directory (absolute for songs outside it)
+	Created int64    `json:"created"`
+	Updated int64    `json:"updated"`
+}
+
+// PlaylistSummary describes a playlist without its songs
+type PlaylistSummary struct {
+	Name    string `json:"name"`
+	Songs   int    `json:"songs"`
+	Active  bool   `json:"active"`
+	Created int64  `json:"created"`
+	Updated int64  `json:"updated"`
+}
+
+// IPlaylists keeps the named playlists and the named or smart playlist used as rotation source
+type IPlaylists struct {
+	mu          sync.Mutex
+	loaded      bool
+	playlists   map[string]*NamedPlaylist
+	active      string            // Named playlist in rotation
+	activeSmart string            // Smart playlist in rotation
+	last        map[string]string // Last hash played per rotation source
+}
+
+var Playlists = &IPlaylists{
+	playlists: make(map[string]*NamedPlaylist),
+	last:      make(map[string]string),
+}
+
+type playlistsData struct {
+	Playlists   []*NamedPlaylist `json:"playlists"`
+	Active      string           `json:"active"`
+	ActiveSmart string           `json:"active_smart,omitempty"`
+}
+
+func init() {
+	RegisterTrackSelector(SelectorPriorityPlaylist, playlistSelector{})
+}
+
+func (p *IPlaylists) loadLocked() {
+	if p.loaded {
+		return
+	}
+	p.loaded = true
+
+	var data playlistsData
+	if err := LoadJSON(playlistsFile, &data); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load playlists: %v", err))
+	}
+	for _, playlist := range data.Playlists {
+		p.playlists[playlist.Name] = playlist
+	}
+	if _, ok := p.playlists[data.Active]; ok {
+		p.active = data.Active
+	}
+	if _, ok := Config.SmartPlaylists[data.ActiveSmart]; ok {
+		p.activeSmart = data.ActiveSmart
+	}
+}
+
+func (p *IPlaylists) saveLocked() {
+	data := playlistsData{Active: p.active, ActiveSmart: p.activeSmart}
+	for _, name := range p.namesLocked() {
+		data.Playlists = append(data.Playlists, p.playlists[name])
+	}
+	if err := SaveJSON(playlistsFile, data); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save playlists: %v", err))
+	}
+}
+
+func (p *IPlaylists) namesLocked() []string {
+	names := make([]string, 0, len(p.playlists))
+	for name := range p.playlists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *IPlaylists) getLocked(name string) (*NamedPlaylist, error) {
+	p.loadLocked()
+	playlist, ok := p.playlists[name]
+	if !ok {
+		return nil, fmt.Errorf("playlist not found")
+	}
+	return playlist, nil
+}
+
+func validPlaylistName(name string) error {
+	if name == "" {
+		return fmt.Errorf("playlist name is required")
+	}
+	if len(name) > 100 || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("playlist name must be at most 100 characters without slashes")
+	}
+	return nil
+}
+
+// songPathForStorage turns a song hash into the path stored in a playlist
+func songPathForStorage(hash string) (string, error) {
+	path, ok := FindSongByHash(hash)
+	if !ok {
+		return "", fmt.Errorf("song hash not found")
+	}
+	return storedSongPath(path), nil
+}
+
+// inMusicDirectory reports whether a file path lies inside the music directory
+func inMusicDirectory(path string) bool {
+	directory, err := filepath.Abs(Config.Directory)
+	if err != nil {
+		return false
+	}
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	relative, err := filepath.Rel(directory, absolute)
+	return err == nil && filepath.IsLocal(relative)
+}
+
+// storedSongPath turns a file path into the form stored in a playlist
+func storedSongPath(path string) string {
+	if relative, err := filepath.Rel(Config.Directory, path); err == nil && !strings.HasPrefix(relative, "..") {
+		return filepath.ToSlash(relative)
+	}
+	return path
+}
+
+// renameSong updates the playlists containing a song that was moved
+func (p *IPlaylists) renameSong(oldPath, newPath string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	oldSong, newSong := storedSongPath(oldPath), storedSongPath(newPath)
+	renamed := false
+	for _, playlist := range p.playlists {
+		for i, song := range playlist.Songs {
+			if song == oldSong {
+				playlist.Songs[i] = newSong
+				renamed = true
+			}
+		}
+	}
+	if renamed {
+		p.saveLocked()
+	}
+}
+
+// playlistSongPath resolves a stored playlist entry to a file path
+func playlistSongPath(song string) string {
+	path := filepath.FromSlash(song)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(Config.Directory, path)
+}
+
+// List returns all playlists sorted by name
+func (p *IPlaylists) List() []PlaylistSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	list := []PlaylistSummary{}
+	for _, name := range p.namesLocked() {
+		playlist := p.playlists[name]
+		list = append(list, PlaylistSummary{
+			Name:    playlist.Name,
+			Songs:   len(playlist.Songs),
+			Active:  name == p.active,
+			Created: playlist.Created,
+			Updated: playlist.Updated,
+		})
+	}
+	return list
+}
+
+// Get returns a copy of a playlist
+func (p *IPlaylists) Get(name string) (NamedPlaylist, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, err := p.getLocked(name)
+	if err != nil {
+		return NamedPlaylist{}, err
+	}
+	copied := *playlist
+	copied.Songs = append([]string{}, playlist.Songs...)
+	return copied, nil
+}
+
+// SongHashes returns the hashes of the playlist songs that exist, registering them in SongHashMap
+func (p *IPlaylists) SongHashes(name string) ([]string, error) {
+	playlist, err := p.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []string{}
+	for _, song := range playlist.Songs {
+		path := playlistSongPath(song)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		hash := Library.HashForPath(path)
+		SongHashMap.Store(hash, path)
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// Create adds an empty playlist
+func (p *IPlaylists) Create(name string) error {
+	if err := validPlaylistName(name); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	if _, ok := p.playlists[name]; ok {
+		return fmt.Errorf("playlist already exists")
+	}
+	now := time.Now().UnixMilli()
+	p.playlists[name] = &NamedPlaylist{Name: name, Songs: []string{}, Created: now, Updated: now}
+	p.saveLocked()
+	return nil
+}
+
+// Rename changes the name of a playlist
+func (p *IPlaylists) Rename(name, newName string) error {
+	if err := validPlaylistName(newName); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, err := p.getLocked(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.playlists[newName]; ok {
+		return fmt.Errorf("playlist already exists")
+	}
+
+	delete(p.playlists, name)
+	playlist.Name = newName
+	playlist.Updated = time.Now().UnixMilli()
+	p.playlists[newName] = playlist
+	if p.active == name {
+		p.active = newName
+	}
+	p.last[playlistSourcePrefix+newName] = p.last[playlistSourcePrefix+name]
+	delete(p.last, playlistSourcePrefix+name)
+	p.saveLocked()
+	return nil
+}
+
+// Delete removes a playlist; deleting the active playlist returns rotation to the library
+func (p *IPlaylists) Delete(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.getLocked(name); err != nil {
+		return err
+	}
+	delete(p.playlists, name)
+	delete(p.last, playlistSourcePrefix+name)
+	if p.active == name {
+		p.active = ""
+	}
+	p.saveLocked()
+	return nil
+}
+
+// Add inserts a song at index (-1 or past the end appends)
+func (p *IPlaylists) Add(name, hash string, index int) error {
+	song, err := songPathForStorage(hash)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, err := p.getLocked(name)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index > len(playlist.Songs) {
+		index = len(playlist.Songs)
+	}
+	playlist.Songs = append(playlist.Songs, "")
+	copy(playlist.Songs[index+1:], playlist.Songs[index:])
+	playlist.Songs[index] = song
+	playlist.Updated = time.Now().UnixMilli()
+	p.saveLocked()
+	return nil
+}
+
+// Remove deletes the song at index
+func (p *IPlaylists) Remove(name string, index int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, err := p.getLocked(name)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(playlist.Songs) {
+		return fmt.Errorf("invalid index")
+	}
+	playlist.Songs = append(playlist.Songs[:index], playlist.Songs[index+1:]...)
+	playlist.Updated = time.Now().UnixMilli()
+	p.saveLocked()
+	return nil
+}
+
+// Reorder moves the song at moveFrom to moveTo
+func (p *IPlaylists) Reorder(name string, moveFrom, moveTo int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	playlist, err := p.getLocked(name)
+	if err != nil {
+		return err
+	}
+	if moveFrom < 0 || moveFrom >= len(playlist.Songs) || moveTo < 0 || moveTo >= len(playlist.Songs) {
+		return fmt.Errorf("invalid from/to indices")
+	}
+
+	song := playlist.Songs[moveFrom]
+	songs := append(playlist.Songs[:moveFrom:moveFrom], playlist.Songs[moveFrom+1:]...)
+	reordered := make([]string, 0, len(playlist.Songs))
+	reordered = append(reordered, songs[:moveTo]...)
+	reordered = append(reordered, song)
+	reordered = append(reordered, songs[moveTo:]...)
+	playlist.Songs = reordered
+	playlist.Updated = time.Now().UnixMilli()
+	p.saveLocked()
+	return nil
+}
+
+// SetSongs replaces the songs of a playlist with imported files, creating it if needed.
+// Files outside the music directory are skipped. Returns the number of songs stored.
+func (p *IPlaylists) SetSongs(name string, paths []string) (int, error) {
+	if err := validPlaylistName(name); err != nil {
+		return 0, err
+	}
+
+	songs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !inMusicDirectory(path) {
+			Logger.Info(fmt.Sprintf("Playlist import: skipped %s outside the music directory", path))
+			continue
+		}
+		hash := Library.HashForPath(path)
+		SongHashMap.Store(hash, path)
+		song, err := songPathForStorage(hash)
+		if err != nil {
+			return 0, err
+		}
+		songs = append(songs, song)
+	}
+	if len(songs) == 0 {
+		return 0, fmt.Errorf("no mp3 files of the music directory found in the playlist")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	now := time.Now().UnixMilli()
+	playlist, ok := p.playlists[name]
+	if !ok {
+		playlist = &NamedPlaylist{Name: name, Created: now}
+		p.playlists[name] = playlist
+	}
+	playlist.Songs = songs
+	playlist.Updated = now
+	p.saveLocked()
+	return len(songs), nil
+}
+
+// LoadIntoQueue adds the playlist songs to the playout queue, optionally replacing it
+func (p *IPlaylists) LoadIntoQueue(name string, replace bool) (int, error) {
+	hashes, err := p.SongHashes(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(hashes) == 0 {
+		return 0, fmt.Errorf("playlist has no playable songs")
+	}
+
+	if replace {
+		MusicReader.ClearPlaylist()
+	}
+	for _, hash := range hashes {
+		MusicReader.AddToPlaylist(hash)
+	}
+	return len(hashes), nil
+}
+
+// SetActive makes a playlist the rotation source; an empty name returns rotation to the library
+func (p *IPlaylists) SetActive(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	if name != "" {
+		if _, ok := p.playlists[name]; !ok {
+			return fmt.Errorf("playlist not found")
+		}
+	}
+	p.active = name
+	p.activeSmart = ""
+	p.saveLocked()
+	return nil
+}
+
+// SetActiveSmart makes a smart playlist the rotation source; an empty name returns rotation to the library
+func (p *IPlaylists) SetActiveSmart(name string) error {
+	if _, ok := Config.SmartPlaylists[name]; name != "" && !ok {
+		return fmt.Errorf("smart playlist not found")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	p.activeSmart = name
+	p.active = ""
+	p.saveLocked()
+	return nil
+}
+
+// Active returns the name of the named playlist used as rotation source
+func (p *IPlaylists) Active() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+	return p.active
+}
+
+// ActiveSmart returns the name of the smart playlist used as rotation source
+func (p *IPlaylists) ActiveSmart() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+	return p.activeSmart
+}
+
+// RotationSource returns the source ("playlist:NAME" or "smart:NAME") replacing the library, if any
+func (p *IPlaylists) RotationSource() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loadLocked()
+
+	if p.activeSmart != "" {
+		return smartSourcePrefix + p.activeSmart
+	}
+	if p.active != "" {
+		return playlistSourcePrefix + p.active
+	}
+	return ""
+}
+
+// playlistSelector plays the active named or smart playlist instead of the whole library
+type playlistSelector struct{}
+
+func (playlistSelector) Name() string {
+	return "playlist"
+}
+
+func (playlistSelector) Key(now time.Time) string {
+	return Playlists.RotationSource()
+}
+
+func (playlistSelector) Next(now time.Time) (string, bool) {
+	source := Playlists.RotationSource()
+	if source == "" {
+		return "", false
+	}
+	hashes, err := SourceSongHashes(source)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Rotation %s unavailable, using the library: %v", source, err))
+		return "", false
+	}
+
+	random := Config.Random
+	if name := strings.TrimPrefix(source, smartSourcePrefix); name != source {
+		random = Config.SmartPlaylists[name].shuffle()
+	}
+
+	Playlists.mu.Lock()
+	defer Playlists.mu.Unlock()
+	hash := pickNextHash(source, hashes, Playlists.last[source], random)
+	Playlists.last[source] = hash
+	return hash, true
+}
+
+// PlaylistFormat returns the playlist format for a file name or format parameter
+func PlaylistFormat(value string) (string, error) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(value)), ".")
+	if format == "" {
+		format = strings.ToLower(value)
+	}
+	switch format {
+	case PlaylistFormatM3U, PlaylistFormatM3U8, PlaylistFormatPLS:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported playlist format %q (use m3u, m3u8 or pls)", value)
+}
+
+// ParsePlaylist reads the MP3 entries of an M3U/M3U8 or PLS playlist.
+// Relative entries are resolved against base; missing files are skipped.
+func ParsePlaylist(data []byte, format string, base string) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if format == PlaylistFormatPLS {
+			// FileN=path; other keys (TitleN, LengthN, NumberOfEntries) are ignored
+			key, value, found := strings.Cut(line, "=")
+			if !found || !strings.HasPrefix(strings.ToLower(key), "file") {
+				continue
+			}
+			line = strings.TrimSpace(value)
+		} else if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "file://") {
+			if parsed, err := url.Parse(entry); err == nil {
+				entry = parsed.Path
+			}
+		}
+		if !strings.HasSuffix(strings.ToLower(entry), ".mp3") {
+			continue
+		}
+		path := filepath.FromSlash(strings.ReplaceAll(entry, "\\", "/"))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			Logger.Info(fmt.Sprintf("Playlist import: missing file %s", entry))
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// ExportPlaylist writes a playlist as M3U/M3U8 or PLS, with paths relative to the music directory
+func (p *IPlaylists) ExportPlaylist(name, format string) ([]byte, error) {
+	playlist, err := p.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if format == PlaylistFormatPLS {
+		buf.WriteString("[playlist]\n")
+	} else {
+		buf.WriteString("#EXTM3U\n")
+		buf.WriteString("#PLAYLIST:" + playlist.Name + "\n")
+	}
+
+	for i, song := range playlist.Songs {
+		meta, _ := readTrackMeta(playlistSongPath(song))
+		title := meta.Title
+		if meta.Artist != "" && title != "" {
+			title = meta.Artist + " - " + title
+		}
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(song), filepath.Ext(song))
+		}
+
+		if format == PlaylistFormatPLS {
+			number := strconv.Itoa(i + 1)
+			buf.WriteString("File" + number + "=" + song + "\n")
+			buf.WriteString("Title" + number + "=" + title + "\n")
+			buf.WriteString("Length" + number + "=-1\n")
+		} else {
+			buf.WriteString("#EXTINF:-1," + title + "\n")
+			buf.WriteString(song + "\n")
+		}
+	}
+
+	if format == PlaylistFormatPLS {
+		buf.WriteString(fmt.Sprintf("NumberOfEntries=%d\nVersion=2\n", len(playlist.Songs)))
+	}
+	return buf.Bytes(), nil
+}
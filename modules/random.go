@@ -0,0 +1,320 @@
+package modules
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Random playback modes
+const (
+	RandomModeRandom   = "random"   // Independent random picks
+	RandomModeShuffle  = "shuffle"  // Every song once before reshuffling
+	RandomModeWeighted = "weighted" // Random picks weighted by track or folder
+)
+
+// File in the data directory holding the shuffle bags
+const shuffleBagsFile = "shuffle.json"
+
+// Shared random source; seeded from random_seed for reproducible rotations
+var randomSource = struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}{
+	rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+// ShuffleBag holds the songs still to play in the current shuffle cycle of a pool
+type ShuffleBag struct {
+	Remaining []string `json:"remaining"`
+	Played    []string `json:"played"`
+}
+
+var shuffleBags = struct {
+	mu        sync.Mutex
+	loaded    bool
+	bags      map[string]*ShuffleBag
+	saveTimer *time.Timer // Pending save, nil when the saved bags are current
+}{
+	bags: make(map[string]*ShuffleBag),
+}
+
+// IsValidRandomMode reports whether mode is a known random playback mode
+func IsValidRandomMode(mode string) bool {
+	return mode == RandomModeRandom || mode == RandomModeShuffle || mode == RandomModeWeighted
+}
+
+// SeedRandom reseeds the shared random source; 0 seeds from the clock
+func SeedRandom(seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	randomSource.mu.Lock()
+	defer randomSource.mu.Unlock()
+	randomSource.rng = rand.New(rand.NewSource(seed))
+}
+
+// randIntn returns a random number in [0, n) from the shared source
+func randIntn(n int) int {
+	randomSource.mu.Lock()
+	defer randomSource.mu.Unlock()
+	return randomSource.rng.Intn(n)
+}
+
+func randFloat64() float64 {
+	randomSource.mu.Lock()
+	defer randomSource.mu.Unlock()
+	return randomSource.rng.Float64()
+}
+
+// TrackWeight returns the weight of a song: the weight of its path or of its
+// closest folder relative to the music directory, 1 if none is configured
+func TrackWeight(hash string) float64 {
+	if len(Config.Weights) == 0 {
+		return 1
+	}
+
+	path, ok := FindSongByHash(hash)
+	if !ok {
+		return 1
+	}
+	relative, err := filepath.Rel(Config.Directory, path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return 1
+	}
+
+	key := filepath.ToSlash(relative)
+	for {
+		if weight, ok := Config.Weights[key]; ok {
+			return weight
+		}
+		parent := filepath.ToSlash(filepath.Dir(key))
+		if parent == key || parent == "." || parent == "/" {
+			return 1
+		}
+		key = parent
+	}
+}
+
+// pickWeightedHash picks a song with probability proportional to its weight
+// (configured weight in weighted mode, scaled down for disliked songs with dislike_weighting)
+func pickWeightedHash(songHashes []string) string {
+	total := 0.0
+	weights := make([]float64, len(songHashes))
+	for i, hash := range songHashes {
+		weights[i] = 1
+		if Config.RandomMode == RandomModeWeighted {
+			weights[i] = TrackWeight(hash)
+		}
+		if Config.DislikeWeighting {
+			weights[i] *= ratingWeight(hash)
+		}
+		total += weights[i]
+	}
+	if total <= 0 {
+		return songHashes[randIntn(len(songHashes))]
+	}
+
+	target := randFloat64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return songHashes[i]
+		}
+	}
+	return songHashes[len(songHashes)-1]
+}
+
+// pickRandomHash picks one of the candidates according to the random mode
+func pickRandomHash(candidates []string) string {
+	if Config.RandomMode == RandomModeWeighted || Config.DislikeWeighting {
+		return pickWeightedHash(candidates)
+	}
+	return candidates[randIntn(len(candidates))]
+}
+
+func shuffled(hashes []string) []string {
+	result := make([]string, len(hashes))
+	copy(result, hashes)
+
+	randomSource.mu.Lock()
+	randomSource.rng.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	randomSource.mu.Unlock()
+	return result
+}
+
+// loadShuffleBagsLocked restores the shuffle bags saved by a previous run
+func loadShuffleBagsLocked() {
+	if shuffleBags.loaded {
+		return
+	}
+	shuffleBags.loaded = true
+
+	if err := LoadJSON(shuffleBagsFile, &shuffleBags.bags); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load shuffle bags: %v", err))
+	}
+	if shuffleBags.bags == nil {
+		shuffleBags.bags = make(map[string]*ShuffleBag)
+	}
+}
+
+// scheduleShuffleBagsSaveLocked saves the bags within stateSaveInterval, like the
+// playout state, so picks don't write the file every time
+func scheduleShuffleBagsSaveLocked() {
+	if shuffleBags.saveTimer == nil {
+		shuffleBags.saveTimer = time.AfterFunc(stateSaveInterval, saveShuffleBags)
+	}
+}
+
+// saveShuffleBags writes a copy of the bags taken under the lock
+func saveShuffleBags() {
+	shuffleBags.mu.Lock()
+	shuffleBags.saveTimer = nil
+	bags := make(map[string]*ShuffleBag, len(shuffleBags.bags))
+	for pool, bag := range shuffleBags.bags {
+		bags[pool] = &ShuffleBag{
+			Remaining: append([]string{}, bag.Remaining...),
+			Played:    append([]string{}, bag.Played...),
+		}
+	}
+	shuffleBags.mu.Unlock()
+
+	if err := SaveJSON(shuffleBagsFile, bags); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save shuffle bags: %v", err))
+	}
+}
+
+// syncWithPool drops songs that left the pool and slots new songs into the remaining cycle
+func (bag *ShuffleBag) syncWithPool(songHashes []string) {
+	inPool := make(map[string]bool, len(songHashes))
+	for _, hash := range songHashes {
+		inPool[hash] = true
+	}
+
+	known := make(map[string]bool, len(songHashes))
+	filter := func(hashes []string) []string {
+		kept := hashes[:0]
+		for _, hash := range hashes {
+			if inPool[hash] && !known[hash] {
+				known[hash] = true
+				kept = append(kept, hash)
+			}
+		}
+		return kept
+	}
+	bag.Remaining = filter(bag.Remaining)
+	bag.Played = filter(bag.Played)
+
+	for _, hash := range songHashes {
+		if known[hash] {
+			continue
+		}
+		position := randIntn(len(bag.Remaining) + 1)
+		bag.Remaining = append(bag.Remaining, "")
+		copy(bag.Remaining[position+1:], bag.Remaining[position:])
+		bag.Remaining[position] = hash
+	}
+}
+
+// refill starts a new cycle. With dislike weighting a song joins the cycle with a
+// probability of its rating weight; the songs left out count as played so they wait for the next one.
+func (bag *ShuffleBag) refill(songHashes []string) {
+	if !Config.DislikeWeighting {
+		bag.Remaining = shuffled(songHashes)
+		bag.Played = nil
+		return
+	}
+
+	var kept, skipped []string
+	for _, hash := range songHashes {
+		if randFloat64() < ratingWeight(hash) {
+			kept = append(kept, hash)
+		} else {
+			skipped = append(skipped, hash)
+		}
+	}
+	if len(kept) == 0 {
+		kept, skipped = songHashes, nil
+	}
+	bag.Remaining = shuffled(kept)
+	bag.Played = skipped
+}
+
+// renameShuffledSong moves a song to its new hash in every shuffle bag, keeping its place in the cycle
+func renameShuffledSong(oldHash, newHash string) {
+	shuffleBags.mu.Lock()
+	defer shuffleBags.mu.Unlock()
+	loadShuffleBagsLocked()
+
+	changed := false
+	for _, bag := range shuffleBags.bags {
+		for _, hashes := range [][]string{bag.Remaining, bag.Played} {
+			for i, hash := range hashes {
+				if hash == oldHash {
+					hashes[i] = newHash
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return
+	}
+	scheduleShuffleBagsSaveLocked()
+}
+
+// pickShuffledHash draws the next song from the shuffle bag of a pool.
+// Each song plays at most once per cycle; the bags are saved shortly after so cycles survive restarts.
+func pickShuffledHash(pool string, songHashes []string) string {
+	shuffleBags.mu.Lock()
+	defer shuffleBags.mu.Unlock()
+
+	loadShuffleBagsLocked()
+	bag, ok := shuffleBags.bags[pool]
+	if !ok {
+		bag = &ShuffleBag{}
+		shuffleBags.bags[pool] = bag
+	}
+
+	bag.syncWithPool(songHashes)
+	if len(bag.Remaining) == 0 {
+		bag.refill(songHashes)
+	}
+
+	// Take the first song in the bag that respects the separation rules
+	current := MusicReader.CurrentSongHash
+	index := -1
+	var plays []recentPlay
+	if separationEnabled() {
+		plays = recentPlaysSnapshot(time.Now())
+	}
+	for i, hash := range bag.Remaining {
+		if hash == current {
+			continue
+		}
+		if plays != nil && violatesSeparation(hash, plays, 1, time.Now()) {
+			continue
+		}
+		index = i
+		break
+	}
+	if index == -1 {
+		index = 0
+		if bag.Remaining[0] == current && len(bag.Remaining) > 1 {
+			index = 1
+		}
+	}
+
+	hash := bag.Remaining[index]
+	bag.Remaining = append(bag.Remaining[:index], bag.Remaining[index+1:]...)
+	bag.Played = append(bag.Played, hash)
+
+	scheduleShuffleBagsSaveLocked()
+	return hash
+}
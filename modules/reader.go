@@ -5,7 +5,6 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"sort"
@@ -42,6 +41,13 @@ type IMusicReader struct {
 	IsIcecastMode  bool
 	IcecastChunks  chan []byte // Channel for receiving normalized Icecast chunks
 	IcecastStopCh  chan struct{} // Signal to stop Icecast processing
+
+	// Fades and live handover (see handover.go)
+	handover readerHandover
+
+	// Last song picked by automation and the key of the selector that picked it
+	automationHash string
+	automationKey  string
 }
 
 type IMusicInfoStoreData struct {
@@ -59,6 +65,7 @@ type IMusicInfo struct {
 	BitRate    string `json:"bitRate"`
 	Url        string `json:"url"`
 	Filename   string `json:"filename"`
+	Artwork    string `json:"artwork"` // Artwork URL, empty without artwork
 }
 
 var MusicReader = IMusicReader{
@@ -85,7 +92,8 @@ type IMusicReaderStoreData struct {
 	Order         int
 }
 
-// GenerateSongHash generates a unique hash for a song filepath
+// GenerateSongHash generates a hash for a song filepath. Songs of the library are
+// identified by their audio instead (see ILibrary); path hashes remain their aliases.
 func GenerateSongHash(filePath string) string {
 	hash := md5.Sum([]byte(filePath))
 	return hex.EncodeToString(hash[:])
@@ -100,89 +108,131 @@ func FindSongByHash(hash string) (string, bool) {
 	return value.(string), true
 }
 
-// GetNextMusicHash calculates what the next music hash would be
-func (musicReader *IMusicReader) GetNextMusicHash(songHashes []string) string {
+// pickNextHash returns the song following last in songHashes, or a random one.
+// pool names the song list (e.g. "library") so shuffle mode keeps a bag per list.
+func pickNextHash(pool string, songHashes []string, last string, random bool) string {
 	if len(songHashes) == 0 {
 		return ""
 	}
 	
+	if random {
+		if Config.RandomMode == RandomModeShuffle {
+			return pickShuffledHash(pool, songHashes)
+		}
+		return pickSeparatedHash(songHashes)
+	}
+	
 	currentIndex := -1
 	for i, hash := range songHashes {
-		if hash == musicReader.CurrentSongHash {
+		if hash == last {
 			currentIndex = i
 			break
 		}
 	}
+	nextIndex := currentIndex + 1
+	if currentIndex == -1 || nextIndex >= len(songHashes) {
+		nextIndex = 0
+	}
+	return songHashes[nextIndex]
+}
+
+// GetNextMusicHash calculates what the next music hash would be
+func (musicReader *IMusicReader) GetNextMusicHash(songHashes []string) string {
+	return pickNextHash("library", songHashes, musicReader.CurrentSongHash, Config.Random)
+}
+
+// nextAutomationHash picks the next song when nothing is queued, using the track selectors
+// (clock wheel, schedule slot or the whole library)
+func (musicReader *IMusicReader) nextAutomationHash() string {
+	hash, key := SelectTrack(time.Now())
 	
-	if Config.Random {
-		randomIndex := rand.Intn(len(songHashes))
-		return songHashes[randomIndex]
-	} else {
-		nextIndex := currentIndex + 1
-		if currentIndex == -1 || nextIndex >= len(songHashes) {
-			nextIndex = 0
-		}
-		return songHashes[nextIndex]
+	musicReader.Lock.Lock()
+	musicReader.automationHash = hash
+	musicReader.automationKey = key
+	musicReader.Lock.Unlock()
+	return hash
+}
+
+// cachedNextOffSchedule reports whether the cached next song was picked by automation
+// for a selector that is no longer active (e.g. a schedule slot that has ended).
+// Manually chosen songs are always kept.
+func (musicReader *IMusicReader) cachedNextOffSchedule() bool {
+	musicReader.Lock.RLock()
+	pickedByAutomation := musicReader.CachedNextHash == musicReader.automationHash
+	pickedKey := musicReader.automationKey
+	musicReader.Lock.RUnlock()
+	
+	if !pickedByAutomation {
+		return false
 	}
+	return !SelectionCurrent(pickedKey, time.Now())
 }
 
+// How many times SelectNextMusic replaces a song that can't be played before giving up until the next tick
+const maxSongPickRetries = 5
+
 func (musicReader *IMusicReader) SelectNextMusic() {
+	// A due jingle plays between songs without touching the song queue
+	if musicReader.playJingleIfDue() {
+		return
+	}
+	
 	_, err := GetMp3FilePaths()
 	if err != nil {
 		Logger.Error(err)
 		return
 	}
 	
-	// Use cached next hash as current song if available, otherwise calculate it
-	if musicReader.CachedNextHash != "" {
+	// Use cached next hash as current song if available, otherwise calculate it.
+	// A song picked for a schedule slot that has since ended is replaced by one from the active slot.
+	if _, exists := FindSongByHash(musicReader.CachedNextHash); exists && !musicReader.cachedNextOffSchedule() {
 		MusicReader.CurrentSongHash = musicReader.CachedNextHash
 	} else {
-		// Calculate next song based on random or sequential mode
-		if Config.Random {
-			randomIndex := rand.Intn(len(SortedSongHashes))
-			MusicReader.CurrentSongHash = SortedSongHashes[randomIndex]
-		} else {
-			// Find current index and move to next
-			currentIndex := -1
-			for i, hash := range SortedSongHashes {
-				if hash == musicReader.CurrentSongHash {
-					currentIndex = i
-					break
-				}
-			}
-			nextIndex := currentIndex + 1
-			if currentIndex == -1 || nextIndex >= len(SortedSongHashes) {
-				nextIndex = 0
-			}
-			MusicReader.CurrentSongHash = SortedSongHashes[nextIndex]
-		}
+		MusicReader.CurrentSongHash = musicReader.nextAutomationHash()
 	}
 	
 	// Determine the NEXT song to be cached and pre-transcoded
 	// Priority 1: Check if there are songs in the playlist
 	var nextHash string
-	if len(musicReader.Playlist) > 0 {
-		musicReader.Lock.Lock()
-		nextHash = musicReader.Playlist[0]
-		musicReader.Playlist = musicReader.Playlist[1:] // Remove from playlist
-		musicReader.Lock.Unlock()
+	if queuedHash, ok := musicReader.popPlaylist(); ok {
+		nextHash = queuedHash
+	} else if requestHash, ok := Requests.nextApproved(); ok {
+		// Priority 2: Approved listener requests
+		nextHash = requestHash
 	} else {
-		// Priority 2: Calculate next song based on random or sequential mode
-		nextHash = musicReader.GetNextMusicHash(SortedSongHashes)
+		// Priority 3: Calculate next song from the schedule or the library
+		nextHash = musicReader.nextAutomationHash()
 	}
 	musicReader.SetCachedNextHash(nextHash)
 
-	filePath, exists := FindSongByHash(musicReader.CurrentSongHash)
-	if !exists {
-		Logger.Error(fmt.Sprintf("Could not find file for hash %s", musicReader.CurrentSongHash))
-		musicReader.SelectNextMusic()
-		return
+	// A song deleted or unreadable since it was picked is replaced by automation,
+	// a bounded number of times, so the queue isn't drained by a burst of misses
+	var filePath string
+	var file *os.File
+	for attempt := 0; file == nil && attempt <= maxSongPickRetries; attempt++ {
+		if attempt > 0 {
+			MusicReader.CurrentSongHash = musicReader.nextAutomationHash()
+		}
+		path, exists := FindSongByHash(musicReader.CurrentSongHash)
+		if !exists {
+			Logger.Error(fmt.Sprintf("Could not find file for hash %s", musicReader.CurrentSongHash))
+			continue
+		}
+
+		// Transcode to standard format for consistent stream quality
+		if transcodedPath, err := TranscodeAudio(path); err == nil {
+			path = transcodedPath
+		}
+		if file, err = os.Open(path); err != nil {
+			Logger.Error(err)
+			file = nil
+			continue
+		}
+		filePath = path
 	}
-	
-	// Transcode to standard format for consistent stream quality
-	transcodedPath, err := TranscodeAudio(filePath)
-	if err == nil {
-		filePath = transcodedPath
+	if file == nil {
+		Logger.Error("No playable song found")
+		return
 	}
 	
 	// Always pre-transcode the next song (whether it's from playlist or random/sequential)
@@ -191,15 +241,27 @@ func (musicReader *IMusicReader) SelectNextMusic() {
 		go PreTranscodeAudioAsync(nextFilePath)
 	}
 	
-	file, err := os.Open(filePath)
-	if err != nil {
-		Logger.Error(err)
-		musicReader.SelectNextMusic()
-		return
-	}
 	MusicReader.File = file
 
 	MusicReader.ResetMusicInfo(filePath)
+	MusicReader.startPendingFadeIn()
+
+	itemType := OnAirTrack
+	if AdBreaks.IsSpot(musicReader.CurrentSongHash) {
+		itemType = OnAirSpot
+	} else {
+		Jingles.songStarted()
+	}
+
+	if info := MusicReader.GetMusicInfoStoreData(); info != nil {
+		AnnounceOnAir(OnAirItem{
+			Type:     itemType,
+			Hash:     musicReader.CurrentSongHash,
+			Title:    info.Title,
+			Artist:   info.Artist,
+			Filename: info.Filename,
+		})
+	}
 }
 
 func (musicReader *IMusicReader) ResetMusicInfo(filePath string) {
@@ -284,6 +346,7 @@ func (musicReader *IMusicReader) GetMusicInfo() *IMusicInfo {
 		SampleRate: info.SampleRate,
 		BitRate:    info.BitRate,
 		Filename:   info.Filename,
+		Artwork:    ArtworkURL(musicReader.CurrentSongHash),
 	}
 }
 
@@ -302,7 +365,7 @@ func (musicReader *IMusicReader) GetNextMusicInfo() *IMusicInfo {
 	// Get or calculate the cached next hash
 	nextHash := musicReader.GetCachedNextHash()
 	if nextHash == "" {
-		nextHash = musicReader.GetNextMusicHash(SortedSongHashes)
+		nextHash = musicReader.nextAutomationHash()
 		musicReader.SetCachedNextHash(nextHash)
 	}
 	
@@ -312,46 +375,31 @@ func (musicReader *IMusicReader) GetNextMusicInfo() *IMusicInfo {
 		return nil
 	}
 	
-	// Extract metadata without loading the file
-	tag, err := id3v2.Open(nextFilePath, id3v2.Options{Parse: true})
-	if err != nil {
-		Logger.Error(err)
-		return nil
-	}
-	defer tag.Close()
-	
-	title := tag.Title()
-	if title == "" {
-		title = filepath.Base(nextFilePath)
-	}
-	artist := tag.Artist()
-	if artist == "" {
-		artist = "Unknown"
-	}
-	
 	// Extract filename without .mp3 extension
 	filename := filepath.Base(nextFilePath)
 	if strings.HasSuffix(filename, ".mp3") {
 		filename = filename[:len(filename)-4]
 	}
-	
-	// Try to detect bitrate and sample rate by reading first frame
-	tempFile, err := os.Open(nextFilePath)
-	if err != nil {
+
+	// Songs of the library are described by the index without opening the file
+	entry, indexed := Library.Entry(nextHash)
+	if !indexed {
+		title, artist := SongTitleArtist(nextHash)
 		return &IMusicInfo{
+			Url:      "/",
 			Title:    title,
 			Artist:   artist,
 			Filename: filename,
+			Artwork:  ArtworkURL(nextHash),
 		}
 	}
-	defer tempFile.Close()
-	
+	title := entry.DisplayTitle()
+	artist := entry.DisplayArtist()
 	sampleRate := ""
 	bitRate := ""
-	frame := mp3lib.NextFrame(tempFile)
-	if frame != nil {
-		sampleRate = fmt.Sprintf("%d", frame.SamplingRate)
-		bitRate = fmt.Sprintf("%d", frame.BitRate/1000)
+	if entry.SampleRate > 0 {
+		sampleRate = fmt.Sprintf("%d", entry.SampleRate)
+		bitRate = fmt.Sprintf("%d", entry.Bitrate)
 	}
 	
 	return &IMusicInfo{
@@ -361,6 +409,7 @@ func (musicReader *IMusicReader) GetNextMusicInfo() *IMusicInfo {
 		Filename:   filename,
 		SampleRate: sampleRate,
 		BitRate:    bitRate,
+		Artwork:    ArtworkURL(nextHash),
 	}
 }
 
@@ -390,18 +439,98 @@ func (musicReader *IMusicReader) GetCachedNextHash() string {
 
 // Thread-safe setter for CachedNextHash
 func (musicReader *IMusicReader) SetCachedNextHash(hash string) {
+	hash = Library.ResolveHash(hash)
 	musicReader.Lock.Lock()
 	defer musicReader.Lock.Unlock()
 	musicReader.CachedNextHash = hash
 }
 
+// popPlaylist removes and returns the first queued song that still exists
+func (musicReader *IMusicReader) popPlaylist() (string, bool) {
+	musicReader.Lock.Lock()
+	defer musicReader.Lock.Unlock()
+
+	for len(musicReader.Playlist) > 0 {
+		hash := musicReader.Playlist[0]
+		musicReader.Playlist = musicReader.Playlist[1:]
+		if _, exists := FindSongByHash(hash); exists {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+// dropSongs removes deleted songs from the queue and the cached next song
+func (musicReader *IMusicReader) dropSongs(removed map[string]bool) {
+	musicReader.Lock.Lock()
+	defer musicReader.Lock.Unlock()
+
+	kept := make([]string, 0, len(musicReader.Playlist))
+	for _, hash := range musicReader.Playlist {
+		if !removed[hash] {
+			kept = append(kept, hash)
+		}
+	}
+	if dropped := len(musicReader.Playlist) - len(kept); dropped > 0 {
+		Logger.Info(fmt.Sprintf("Removed %d deleted songs from the playlist", dropped))
+	}
+	musicReader.Playlist = kept
+	if removed[musicReader.CachedNextHash] {
+		musicReader.CachedNextHash = ""
+	}
+}
+
+// renameSong replaces the hash of a song in the queue, the song on air and the cached next song
+func (musicReader *IMusicReader) renameSong(oldHash, newHash string) {
+	musicReader.Lock.Lock()
+	defer musicReader.Lock.Unlock()
+
+	if musicReader.CurrentSongHash == oldHash {
+		musicReader.CurrentSongHash = newHash
+	}
+
+	for i, hash := range musicReader.Playlist {
+		if hash == oldHash {
+			musicReader.Playlist[i] = newHash
+		}
+	}
+	if musicReader.CachedNextHash == oldHash {
+		musicReader.CachedNextHash = newHash
+	}
+}
+
 // AddToPlaylist adds a song hash to the end of the playlist
 func (musicReader *IMusicReader) AddToPlaylist(hash string) {
+	hash = Library.ResolveHash(hash)
 	musicReader.Lock.Lock()
 	defer musicReader.Lock.Unlock()
 	musicReader.Playlist = append(musicReader.Playlist, hash)
 }
 
+// InsertBreak puts a group of songs (e.g. an ad break) on air right after the current song.
+// The song that was going to play next moves to the front of the queue behind the break.
+func (musicReader *IMusicReader) InsertBreak(hashes []string) {
+	if len(hashes) == 0 {
+		return
+	}
+
+	musicReader.Lock.Lock()
+	defer musicReader.Lock.Unlock()
+
+	queue := make([]string, 0, len(hashes)+len(musicReader.Playlist))
+	queue = append(queue, hashes[1:]...)
+	if musicReader.CachedNextHash != "" {
+		queue = append(queue, musicReader.CachedNextHash)
+	}
+	queue = append(queue, musicReader.Playlist...)
+
+	musicReader.Playlist = queue
+	musicReader.CachedNextHash = hashes[0]
+	if nextFilePath, exists := FindSongByHash(hashes[0]); exists {
+		go PreTranscodeAudioAsync(nextFilePath)
+	}
+}
+
 // RemoveFromPlaylist removes a song at a specific position from the playlist
 func (musicReader *IMusicReader) RemoveFromPlaylist(index int) bool {
 	musicReader.Lock.Lock()
@@ -415,6 +544,20 @@ func (musicReader *IMusicReader) RemoveFromPlaylist(index int) bool {
 	return true
 }
 
+// queuedCounts counts the copies of each hash on air, cached as next or in the playlist
+func (musicReader *IMusicReader) queuedCounts() map[string]int {
+	musicReader.Lock.RLock()
+	defer musicReader.Lock.RUnlock()
+
+	counts := make(map[string]int, len(musicReader.Playlist)+2)
+	for _, hash := range musicReader.Playlist {
+		counts[hash]++
+	}
+	counts[musicReader.CachedNextHash]++
+	counts[musicReader.CurrentSongHash]++
+	return counts
+}
+
 // GetPlaylist returns a copy of the current playlist
 func (musicReader *IMusicReader) GetPlaylist() []string {
 	musicReader.Lock.RLock()
@@ -480,7 +623,7 @@ func (musicReader *IMusicReader) SetInitialBuffer() {
 	var bitRate string
 
 	for i := 0; i < musicReader.InitialFrame; i++ {
-		frame := mp3lib.NextFrame(musicReader.File)
+		frame := musicReader.nextFrame()
 		if frame == nil {
 			musicReader.CloseFile()
 			continue
@@ -515,6 +658,7 @@ func (musicReader *IMusicReader) SetInitialBuffer() {
 		Timeout:       timeout,
 		Order:         1,
 	})
+	writeOutputTaps(initialBuffer)
 }
 
 func (musicReader *IMusicReader) SetUnitBuffer() {
@@ -530,7 +674,7 @@ func (musicReader *IMusicReader) SetUnitBuffer() {
 
 		// Try to read frames from current file
 		for i := 0; i < musicReader.UnitFrame; i++ {
-			frame := mp3lib.NextFrame(musicReader.File)
+			frame := musicReader.nextFrame()
 			if frame == nil {
 				musicReader.CloseFile()
 				continue
@@ -546,6 +690,10 @@ func (musicReader *IMusicReader) SetUnitBuffer() {
 
 		// No frames read - file is exhausted or we have no file
 		if musicReader.NoFile() {
+			// Automation handed the air to a live source - don't load the next track yet
+			if musicReader.IsHeld() {
+				break
+			}
 			musicReader.SelectNextMusic()
 			retry++
 			if retry > maxRetries {
@@ -591,6 +739,7 @@ func (musicReader *IMusicReader) SetUnitBuffer() {
 		Timeout:       actualTimeout,
 		Order:         store.Order + 1,
 	})
+	writeOutputTaps(unitBuffer)
 }
 
 func (musicReader *IMusicReader) StartLoop() {
@@ -613,7 +762,8 @@ func (musicReader *IMusicReader) StartLoop() {
 		}
 		
 		// Skip feeding if in Icecast mode (Icecast processor handles it)
-		if currentMode {
+		// or while automation is held for a live handover
+		if currentMode || musicReader.IsHeld() {
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
@@ -629,6 +779,7 @@ func (musicReader *IMusicReader) StartLoop() {
 		} else {
 			musicReader.SetUnitBuffer()
 		}
+		musicReader.saveStateIfDue()
 
 		musicReader.Sleep()
 	}
@@ -665,7 +816,10 @@ func (musicReader *IMusicReader) EnableIcecastMode() {
 }
 
 // DisableIcecastMode switches back to file streaming mode
+// Automation resumes with the return transition of the configured handover mode
 func (musicReader *IMusicReader) DisableIcecastMode() {
+	musicReader.ResumeFromLive(Config.HandoverMode)
+
 	musicReader.Lock.Lock()
 	musicReader.IsIcecastMode = false
 	musicReader.Lock.Unlock()
@@ -762,6 +916,7 @@ func (musicReader *IMusicReader) ProcessIcecastStream() {
 						Timeout:       timeout,
 						Order:         order,
 					})
+					writeOutputTaps(initialBuffer)
 					initialized = true
 					Logger.Info(fmt.Sprintf("Icecast stream ready (%d KB, %d chunks)", len(initialBuffer)/1024, chunkCount))
 				}
@@ -787,6 +942,7 @@ func (musicReader *IMusicReader) ProcessIcecastStream() {
 				Timeout:       timeout,
 				Order:         order,
 			})
+			writeOutputTaps(unitBuffer)
 			
 			unitBuffer = nil // Reset unit for next cycle
 
@@ -806,6 +962,7 @@ func (musicReader *IMusicReader) ProcessIcecastStream() {
 					Timeout:       timeout,
 					Order:         order,
 				})
+				writeOutputTaps(unitBuffer)
 				unitBuffer = nil
 			}
 			
@@ -826,18 +983,15 @@ func (musicReader *IMusicReader) ProcessIcecastStream() {
 	}
 }
 
-func GetMp3FilePaths() ([]string, error) {
-	type fileInfo struct {
-		path    string
-		modTime time.Time
-	}
-	var mp3Files []fileInfo
-	err := filepath.Walk(Config.Directory, func(path string, info os.FileInfo, err error) error {
+// scanMp3Files returns the paths of all MP3 files under dir, sorted alphabetically
+func scanMp3Files(dir string) ([]string, error) {
+	var mp3Files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".mp3") {
-			mp3Files = append(mp3Files, fileInfo{path, info.ModTime()})
+			mp3Files = append(mp3Files, path)
 		}
 		return nil
 	})
@@ -845,39 +999,55 @@ func GetMp3FilePaths() ([]string, error) {
 		return nil, err
 	}
 
-	if len(mp3Files) == 0 {
-		Logger.Error("There are no MP3 files in the music directory.")
-		return nil, fmt.Errorf("no mp3 files found in %s", Config.Directory)
-	}
+	sort.Strings(mp3Files)
+	return mp3Files, nil
+}
 
-	// Sort alphabetically by path
-	sort.Slice(mp3Files, func(i, j int) bool {
-		return mp3Files[i].path < mp3Files[j].path
-	})
+// GetMp3FilePaths returns the songs of the music directory from the library index.
+// The directory is only walked while the index is empty.
+func GetMp3FilePaths() ([]string, error) {
+	paths := Library.Paths()
+	if len(paths) == 0 {
+		if _, err := Library.Refresh(); err != nil {
+			return nil, err
+		}
+		paths = Library.Paths()
+	}
 
-	// Extract paths and generate hashes
-	result := make([]string, len(mp3Files))
-	hashes := make([]string, len(mp3Files))
-	
-	for i, f := range mp3Files {
-		result[i] = f.path
-		hash := GenerateSongHash(f.path)
-		hashes[i] = hash
-		SongHashMap.Store(hash, f.path)
+	if len(paths) == 0 {
+		Logger.Error("There are no MP3 files in the music directory.")
+		return nil, fmt.Errorf("no mp3 files found in %s", Config.Directory)
 	}
-	
-	// Store sorted hashes globally
-	SortedSongHashes = hashes
-	
-	return result, nil
+	return paths, nil
 }
 
 func InitReader() {
+	// Resume the queue and the song on air before the previous run stopped
+	MusicReader.restoreState()
+
 	go func() {
 		MusicReader.StartLoop()
 	}()
 	Logger.Info(fmt.Sprintf("Music directory is %s.", Config.Directory))
+
+	// Pick up songs added, changed or removed while the station was stopped
+	StartLibraryRefreshRoutine()
+
+	// Update the library as soon as songs are added, changed or removed
+	StartLibraryWatcher()
 	
 	// Start cache cleanup routine for normalized audio cache
 	StartCacheCleanupRoutine()
+
+	// Start retention routine for live show recordings
+	StartArchiveCleanupRoutine()
+
+	// Start continuous aircheck recording of the outgoing stream
+	StartAircheck()
+
+	// Start scheduled ad breaks
+	StartBreakScheduler()
+
+	// Start retention routine for the play history
+	StartHistoryCleanupRoutine()
 }
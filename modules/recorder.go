@@ -0,0 +1,333 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording sources for live shows
+const (
+	RecordSourceInput  = "input"  // Raw audio as sent by the live source
+	RecordSourceOutput = "output" // The outgoing station stream during the live session
+)
+
+// Name of the output tap used when recording the outgoing stream
+const liveRecorderTap = "live-recorder"
+
+// RecordingInfo describes an archived live show
+type RecordingInfo struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	Created int64  `json:"created"`
+	HasCue  bool   `json:"has_cue"`
+	Active  bool   `json:"active"`
+}
+
+type cueTrack struct {
+	Title  string
+	Offset time.Duration
+	Time   time.Time
+}
+
+// ILiveRecorder records each live source session to a dated MP3 file with a cue sheet
+type ILiveRecorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	name      string
+	title     string
+	startedAt time.Time
+	tracks    []cueTrack
+	bytes     int64
+}
+
+var LiveRecorder = &ILiveRecorder{}
+
+// Recording names are generated by the recorder; anything else is rejected by the API
+var recordingNamePattern = regexp.MustCompile(`^live-[0-9]{8}-[0-9]{6}(-[a-z0-9-]+)?\.(mp3|cue)$`)
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// StartSession opens a new recording for a live source session
+func (r *ILiveRecorder) StartSession(sourceName string) {
+	if !Config.RecordLive {
+		return
+	}
+
+	// Register the tap outside the recorder lock: taps call back into the recorder
+	if r.startLocked(sourceName) && Config.RecordSource == RecordSourceOutput {
+		AddOutputTap(liveRecorderTap, r.write)
+	}
+}
+
+// startLocked opens the recording file and cue sheet for a new session
+func (r *ILiveRecorder) startLocked(sourceName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeLocked()
+
+	if err := os.MkdirAll(Config.ArchiveDir, 0755); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create archive directory: %v", err))
+		return false
+	}
+
+	now := time.Now()
+	name := "live-" + now.Format("20060102-150405")
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(sourceName), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.Trim(slug[:40], "-")
+	}
+	if slug != "" {
+		name += "-" + slug
+	}
+
+	file, err := os.Create(filepath.Join(Config.ArchiveDir, name+".mp3"))
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create recording: %v", err))
+		return false
+	}
+
+	r.file = file
+	r.name = name
+	r.title = sourceName
+	r.startedAt = now
+	r.bytes = 0
+	r.tracks = nil
+	if sourceName != "" {
+		r.tracks = append(r.tracks, cueTrack{Title: sourceName, Time: now})
+	}
+	r.writeCueLocked()
+
+	Logger.Info(fmt.Sprintf("Recording live session to %s.mp3 (%s)", name, Config.RecordSource))
+	return true
+}
+
+// StopSession closes the current recording
+func (r *ILiveRecorder) StopSession() {
+	RemoveOutputTap(liveRecorderTap)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeLocked()
+}
+
+func (r *ILiveRecorder) closeLocked() {
+	if r.file == nil {
+		return
+	}
+
+	r.writeCueLocked()
+	if err := r.file.Close(); err != nil {
+		Logger.Error(err)
+	}
+	Logger.Info(fmt.Sprintf("Recording %s.mp3 finished (%.2f MB, %s)", r.name, float64(r.bytes)/(1024*1024), time.Since(r.startedAt).Round(time.Second)))
+	r.file = nil
+	r.name = ""
+}
+
+// RecordInput records a chunk received from the live source when recording the raw input
+func (r *ILiveRecorder) RecordInput(chunk []byte) {
+	if Config.RecordSource != RecordSourceInput {
+		return
+	}
+	r.write(chunk)
+}
+
+func (r *ILiveRecorder) write(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+
+	n, err := r.file.Write(data)
+	r.bytes += int64(n)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Recording write failed, stopping recording: %v", err))
+		r.closeLocked()
+	}
+}
+
+// AddMetadata adds a timestamped title update to the cue sheet of the current recording
+func (r *ILiveRecorder) AddMetadata(title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil || title == "" {
+		return
+	}
+	if len(r.tracks) > 0 && r.tracks[len(r.tracks)-1].Title == title {
+		return
+	}
+
+	now := time.Now()
+	r.tracks = append(r.tracks, cueTrack{Title: title, Offset: now.Sub(r.startedAt), Time: now})
+	r.writeCueLocked()
+}
+
+// writeCueLocked rewrites the cue sheet next to the recording
+func (r *ILiveRecorder) writeCueLocked() {
+	var cue strings.Builder
+	cue.WriteString(fmt.Sprintf("REM DATE %s\n", r.startedAt.Format("2006-01-02")))
+	cue.WriteString(fmt.Sprintf("REM STARTED %s\n", r.startedAt.Format(time.RFC3339)))
+	cue.WriteString(fmt.Sprintf("PERFORMER %q\n", cueEscape(Config.Name)))
+	if r.title != "" {
+		cue.WriteString(fmt.Sprintf("TITLE %q\n", cueEscape(r.title)))
+	}
+	cue.WriteString(fmt.Sprintf("FILE %q MP3\n", r.name+".mp3"))
+
+	for i, track := range r.tracks {
+		// Cue sheet frames are 1/75 of a second
+		frames := int64(track.Offset / (time.Second / 75))
+		cue.WriteString(fmt.Sprintf("  TRACK %02d AUDIO\n", i+1))
+		cue.WriteString(fmt.Sprintf("    TITLE %q\n", cueEscape(track.Title)))
+		cue.WriteString(fmt.Sprintf("    REM WALLCLOCK %s\n", track.Time.Format(time.RFC3339)))
+		cue.WriteString(fmt.Sprintf("    INDEX 01 %02d:%02d:%02d\n", frames/75/60, frames/75%60, frames%75))
+	}
+
+	cuePath := filepath.Join(Config.ArchiveDir, r.name+".cue")
+	if err := os.WriteFile(cuePath, []byte(cue.String()), 0644); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to write cue sheet: %v", err))
+	}
+}
+
+func cueEscape(value string) string {
+	return strings.ReplaceAll(value, `"`, "'")
+}
+
+// ActiveRecording returns the name of the recording in progress, if any
+func (r *ILiveRecorder) ActiveRecording() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return ""
+	}
+	return r.name + ".mp3"
+}
+
+// ListRecordings returns all archived live shows, newest first
+func ListRecordings() ([]RecordingInfo, error) {
+	entries, err := os.ReadDir(Config.ArchiveDir)
+	if os.IsNotExist(err) {
+		return []RecordingInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	active := LiveRecorder.ActiveRecording()
+	recordings := []RecordingInfo{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !recordingNamePattern.MatchString(name) || !strings.HasSuffix(name, ".mp3") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		_, cueErr := os.Stat(filepath.Join(Config.ArchiveDir, strings.TrimSuffix(name, ".mp3")+".cue"))
+		recordings = append(recordings, RecordingInfo{
+			Name:    name,
+			Size:    info.Size(),
+			Created: info.ModTime().UnixMilli(),
+			HasCue:  cueErr == nil,
+			Active:  name == active,
+		})
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].Name > recordings[j].Name
+	})
+	return recordings, nil
+}
+
+// RecordingPath resolves a recording or cue sheet name to its path in the archive
+func RecordingPath(name string) (string, error) {
+	if !recordingNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid recording name")
+	}
+	path := filepath.Join(Config.ArchiveDir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("recording not found")
+	}
+	return path, nil
+}
+
+// DeleteRecording removes a recording and its cue sheet
+func DeleteRecording(name string) error {
+	path, err := RecordingPath(name)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(name, ".mp3") {
+		return fmt.Errorf("invalid recording name")
+	}
+	if name == LiveRecorder.ActiveRecording() {
+		return fmt.Errorf("recording is in progress")
+	}
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	cuePath := strings.TrimSuffix(path, ".mp3") + ".cue"
+	if err := os.Remove(cuePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	Logger.Info(fmt.Sprintf("Deleted recording %s", name))
+	return nil
+}
+
+// CleanOldRecordings deletes recordings older than the configured retention
+func CleanOldRecordings() error {
+	if Config.ArchiveRetentionDays <= 0 {
+		return nil
+	}
+
+	recordings, err := ListRecordings()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -Config.ArchiveRetentionDays).UnixMilli()
+	for _, recording := range recordings {
+		if recording.Active || recording.Created >= cutoff {
+			continue
+		}
+		if err := DeleteRecording(recording.Name); err != nil {
+			Logger.Error(fmt.Sprintf("Failed to delete old recording %s: %v", recording.Name, err))
+		}
+	}
+	return nil
+}
+
+// StartArchiveCleanupRoutine periodically applies the recording retention
+func StartArchiveCleanupRoutine() {
+	if !Config.RecordLive || Config.ArchiveRetentionDays <= 0 {
+		return
+	}
+
+	go func() {
+		if err := CleanOldRecordings(); err != nil {
+			Logger.Error(fmt.Sprintf("Initial archive cleanup failed: %v", err))
+		}
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := CleanOldRecordings(); err != nil {
+				Logger.Error(fmt.Sprintf("Scheduled archive cleanup failed: %v", err))
+			}
+		}
+	}()
+
+	Logger.Info(fmt.Sprintf("Archive cleanup routine started (retention: %d days)", Config.ArchiveRetentionDays))
+}
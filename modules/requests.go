@@ -0,0 +1,371 @@
+package modules
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// File in the data directory holding the request queue
+const requestsFile = "requests.json"
+
+// Listener request states
+const (
+	RequestPending  = "pending"  // Waiting for moderation
+	RequestApproved = "approved" // Waiting for its turn in the playout queue
+	RequestQueued   = "queued"   // Picked as the next song
+	RequestPlayed   = "played"
+	RequestRejected = "rejected"
+)
+
+// Number of finished (played or rejected) requests kept for the API and repeat checks
+const maxFinishedRequests = 200
+
+// ListenerRequest is a song requested by a listener
+type ListenerRequest struct {
+	ID        string `json:"id"`
+	Hash      string `json:"hash"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Name      string `json:"name,omitempty"`    // Listener name
+	Message   string `json:"message,omitempty"` // Dedication or message
+	Requester string `json:"-"`                 // Rate limit key (client IP)
+	Status    string `json:"status"`
+	Time      int64  `json:"time"`              // Unix milliseconds when requested
+	Updated   int64  `json:"updated,omitempty"` // Unix milliseconds of the last status change
+}
+
+// IRequestQueue holds listener requests and merges approved ones into the playout
+type IRequestQueue struct {
+	mu             sync.Mutex
+	loaded         bool
+	requests       []*ListenerRequest
+	autoApprove    bool
+	songsSince     int                    // Songs aired since the last request
+	recentByCaller map[string][]time.Time // Rate limit key -> request times
+	callersSwept   time.Time
+}
+
+var Requests = &IRequestQueue{
+	recentByCaller: make(map[string][]time.Time),
+}
+
+func init() {
+	AddOnAirListener(Requests.onAir)
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// pruneCallerLocked drops the request times of a caller that left the rate limit
+// window, and the caller itself once none are left. It returns the times kept.
+func (q *IRequestQueue) pruneCallerLocked(caller string, now time.Time, window time.Duration) []time.Time {
+	recent := q.recentByCaller[caller][:0]
+	for _, t := range q.recentByCaller[caller] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(q.recentByCaller, caller)
+	} else {
+		q.recentByCaller[caller] = recent
+	}
+	return recent
+}
+
+// loadLocked restores the request queue saved by a previous run
+func (q *IRequestQueue) loadLocked() {
+	if q.loaded {
+		return
+	}
+	q.loaded = true
+	q.autoApprove = Config.RequestAutoApprove
+	q.songsSince = Config.RequestMinGapTracks
+
+	// A request picked as next song before a restart stays queued when the playout
+	// state brings it back as next song; otherwise nextApproved puts it back in line
+	if err := LoadJSON(requestsFile, &q.requests); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load requests: %v", err))
+	}
+}
+
+func (q *IRequestQueue) saveLocked() {
+	// Keep all open requests and the most recent finished ones
+	finished := 0
+	kept := make([]*ListenerRequest, 0, len(q.requests))
+	for i := len(q.requests) - 1; i >= 0; i-- {
+		request := q.requests[i]
+		if request.Status == RequestPlayed || request.Status == RequestRejected {
+			finished++
+			if finished > maxFinishedRequests {
+				continue
+			}
+		}
+		kept = append(kept, request)
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	q.requests = kept
+
+	if err := SaveJSON(requestsFile, q.requests); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save requests: %v", err))
+	}
+}
+
+// Submit adds a listener request after checking the rate limit and repeat rules
+func (q *IRequestQueue) Submit(hash, requester, name, message string) (*ListenerRequest, error) {
+	if !Config.RequestsEnabled {
+		return nil, fmt.Errorf("requests are disabled")
+	}
+	if _, ok := FindSongByHash(hash); !ok || AdBreaks.IsSpot(hash) {
+		return nil, fmt.Errorf("song hash not found")
+	}
+	hash = Library.ResolveHash(hash)
+
+	now := time.Now()
+	repeatWindow := time.Duration(Config.RequestRepeatMinutes) * time.Minute
+
+	// Refuse songs that are on air, coming up next or played recently
+	if hash == MusicReader.CurrentSongHash || hash == MusicReader.GetCachedNextHash() {
+		return nil, fmt.Errorf("this song is playing or coming up next")
+	}
+	for _, queued := range MusicReader.GetPlaylist() {
+		if queued == hash {
+			return nil, fmt.Errorf("this song is already in the queue")
+		}
+	}
+	for _, play := range recentPlaysSnapshot(now) {
+		if play.Hash == hash && now.Sub(play.Time) < repeatWindow {
+			return nil, fmt.Errorf("this song was played recently, try again later")
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	// Rate limit per client IP
+	window := time.Duration(Config.RequestWindowMinutes) * time.Minute
+	if now.Sub(q.callersSwept) >= time.Minute {
+		q.callersSwept = now
+		for caller := range q.recentByCaller {
+			q.pruneCallerLocked(caller, now, window)
+		}
+	}
+	recent := q.pruneCallerLocked(requester, now, window)
+	if Config.RequestLimit > 0 && len(recent) >= Config.RequestLimit {
+		return nil, fmt.Errorf("request limit reached (%d per %d minutes)", Config.RequestLimit, Config.RequestWindowMinutes)
+	}
+
+	open := 0
+	for _, request := range q.requests {
+		if request.Hash == hash && request.Status != RequestRejected && now.Sub(time.UnixMilli(request.Time)) < repeatWindow {
+			return nil, fmt.Errorf("this song was requested recently")
+		}
+		if request.Status == RequestPending || request.Status == RequestApproved {
+			open++
+		}
+	}
+	if Config.RequestMaxOpen > 0 && open >= Config.RequestMaxOpen {
+		return nil, fmt.Errorf("the request queue is full, try again later")
+	}
+
+	meta, _ := GetTrackMeta(hash)
+	request := &ListenerRequest{
+		ID:        newRequestID(),
+		Hash:      hash,
+		Title:     meta.Title,
+		Artist:    meta.Artist,
+		Name:      name,
+		Message:   message,
+		Requester: requester,
+		Status:    RequestPending,
+		Time:      now.UnixMilli(),
+	}
+	if q.autoApprove {
+		request.Status = RequestApproved
+		request.Updated = request.Time
+	}
+
+	q.requests = append(q.requests, request)
+	q.recentByCaller[requester] = append(q.recentByCaller[requester], now)
+	q.saveLocked()
+
+	Logger.Info(fmt.Sprintf("Listener request %s: %s - %s (%s)", request.ID, request.Artist, request.Title, request.Status))
+	copied := *request
+	return &copied, nil
+}
+
+// List returns all requests, oldest first
+func (q *IRequestQueue) List() []ListenerRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	list := make([]ListenerRequest, len(q.requests))
+	for i, request := range q.requests {
+		list[i] = *request
+	}
+	return list
+}
+
+// Moderate approves or rejects a pending request
+func (q *IRequestQueue) Moderate(id string, approve bool) (*ListenerRequest, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	for _, request := range q.requests {
+		if request.ID != id {
+			continue
+		}
+		if approve && request.Status != RequestPending {
+			return nil, fmt.Errorf("request is %s", request.Status)
+		}
+		if !approve && request.Status != RequestPending && request.Status != RequestApproved {
+			return nil, fmt.Errorf("request is %s", request.Status)
+		}
+
+		request.Status = RequestRejected
+		if approve {
+			request.Status = RequestApproved
+		}
+		request.Updated = time.Now().UnixMilli()
+		q.saveLocked()
+
+		copied := *request
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("request not found")
+}
+
+// SetAutoApprove switches automatic approval of new requests
+func (q *IRequestQueue) SetAutoApprove(enabled bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+	q.autoApprove = enabled
+}
+
+// AutoApprove reports whether new requests are approved automatically
+func (q *IRequestQueue) AutoApprove() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+	return q.autoApprove
+}
+
+// nextApproved returns the oldest approved request when the merge rules allow a request now
+func (q *IRequestQueue) nextApproved() (string, bool) {
+	if !Config.RequestsEnabled {
+		return "", false
+	}
+
+	upcoming := map[string]bool{MusicReader.GetCachedNextHash(): true}
+	for _, hash := range MusicReader.GetPlaylist() {
+		upcoming[hash] = true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	for _, request := range q.requests {
+		if request.Status != RequestQueued {
+			continue
+		}
+		if upcoming[request.Hash] {
+			// Only one request waits as next song at a time
+			return "", false
+		}
+		// The next song was replaced (e.g. by an admin); the request goes back in line
+		request.Status = RequestApproved
+	}
+	if q.songsSince < Config.RequestMinGapTracks {
+		return "", false
+	}
+
+	for _, request := range q.requests {
+		if request.Status == RequestApproved {
+			request.Status = RequestQueued
+			request.Updated = time.Now().UnixMilli()
+			q.saveLocked()
+			return request.Hash, true
+		}
+	}
+	return "", false
+}
+
+// dropSongs rejects open requests for songs that were deleted
+func (q *IRequestQueue) dropSongs(removed map[string]bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	dropped := 0
+	now := time.Now().UnixMilli()
+	for _, request := range q.requests {
+		switch request.Status {
+		case RequestPending, RequestApproved, RequestQueued:
+			if removed[request.Hash] {
+				request.Status = RequestRejected
+				request.Updated = now
+				dropped++
+			}
+		}
+	}
+	if dropped > 0 {
+		q.saveLocked()
+		Logger.Info(fmt.Sprintf("Rejected %d requests for deleted songs", dropped))
+	}
+}
+
+// renameSong points open requests for a moved song to its new hash
+func (q *IRequestQueue) renameSong(oldHash, newHash string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	renamed := false
+	for _, request := range q.requests {
+		if request.Hash == oldHash {
+			request.Hash = newHash
+			renamed = true
+		}
+	}
+	if renamed {
+		q.saveLocked()
+	}
+}
+
+// onAir marks queued requests as played and counts songs between requests
+func (q *IRequestQueue) onAir(item OnAirItem) {
+	if item.Type != OnAirTrack || !Config.RequestsEnabled {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	for _, request := range q.requests {
+		if request.Status == RequestQueued && request.Hash == item.Hash {
+			request.Status = RequestPlayed
+			request.Updated = item.Time
+			q.songsSince = 0
+			q.saveLocked()
+			Logger.Info(fmt.Sprintf("Playing listener request %s: %s - %s", request.ID, request.Artist, request.Title))
+			return
+		}
+	}
+	q.songsSince++
+}
@@ -0,0 +1,264 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Playback orders for schedule slots
+const (
+	ScheduleOrderShuffle    = "shuffle"
+	ScheduleOrderSequential = "sequential"
+)
+
+// ScheduleSlot maps a weekday/time range to a music source
+type ScheduleSlot struct {
+	Name   string   `json:"name"`
+	Days   []string `json:"days"`   // mon, tue, ... sun; empty means every day
+	Start  string   `json:"start"`  // HH:MM local time
+	End    string   `json:"end"`    // HH:MM local time; earlier than start wraps past midnight
+	Source string   `json:"source"` // Folder or .m3u file, relative to the music directory, "playlist:NAME" or "smart:NAME"
+	Clock  string   `json:"clock"`  // Clock wheel to run instead of a source
+	Order  string   `json:"order"`  // "shuffle" or "sequential"; empty follows the global random flag
+
+	days  map[time.Weekday]bool
+	start time.Duration
+	end   time.Duration
+}
+
+// ScheduleOccurrence is one concrete airing of a slot
+type ScheduleOccurrence struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Clock  string `json:"clock,omitempty"`
+	Order  string `json:"order"`
+	Start  int64  `json:"start"` // Unix milliseconds
+	End    int64  `json:"end"`   // Unix milliseconds
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Last hash played per slot, so sequential slots continue where they left off
+var scheduleState = struct {
+	mu   sync.Mutex
+	last map[string]string
+}{
+	last: make(map[string]string),
+}
+
+// parseClock parses an HH:MM time of day
+func parseClock(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// PrepareSchedule validates schedule slots and fills in their parsed fields
+func PrepareSchedule(slots []ScheduleSlot) error {
+	for i := range slots {
+		slot := &slots[i]
+		if slot.Name == "" {
+			slot.Name = fmt.Sprintf("slot-%d", i+1)
+		}
+		if (slot.Source == "") == (slot.Clock == "") {
+			return fmt.Errorf("schedule slot %s needs either a source or a clock", slot.Name)
+		}
+
+		slot.Order = strings.ToLower(slot.Order)
+		if slot.Order != "" && slot.Order != ScheduleOrderShuffle && slot.Order != ScheduleOrderSequential {
+			return fmt.Errorf("schedule slot %s: order must be shuffle or sequential", slot.Name)
+		}
+
+		slot.days = make(map[time.Weekday]bool)
+		for _, day := range slot.Days {
+			key := strings.ToLower(day)
+			if len(key) > 3 {
+				key = key[:3]
+			}
+			weekday, ok := weekdayNames[key]
+			if !ok {
+				return fmt.Errorf("schedule slot %s: unknown day %q", slot.Name, day)
+			}
+			slot.days[weekday] = true
+		}
+
+		var err error
+		if slot.start, err = parseClock(slot.Start); err != nil {
+			return fmt.Errorf("schedule slot %s: %w", slot.Name, err)
+		}
+		if slot.end, err = parseClock(slot.End); err != nil {
+			return fmt.Errorf("schedule slot %s: %w", slot.Name, err)
+		}
+	}
+	return nil
+}
+
+// occursOn returns the airing of the slot that starts on the given calendar day
+func (slot *ScheduleSlot) occursOn(day time.Time) (time.Time, time.Time, bool) {
+	if len(slot.days) > 0 && !slot.days[day.Weekday()] {
+		return time.Time{}, time.Time{}, false
+	}
+
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	start := midnight.Add(slot.start)
+	end := midnight.Add(slot.end)
+	if !end.After(start) {
+		// Wraps past midnight; equal times cover a full day
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+// occurrence converts a slot airing to its API representation
+func (slot *ScheduleSlot) occurrence(start, end time.Time) ScheduleOccurrence {
+	return ScheduleOccurrence{
+		Name:   slot.Name,
+		Source: slot.Source,
+		Clock:  slot.Clock,
+		Order:  slot.order(),
+		Start:  start.UnixMilli(),
+		End:    end.UnixMilli(),
+	}
+}
+
+// order returns the effective playback order of the slot
+func (slot *ScheduleSlot) order() string {
+	if slot.Order != "" {
+		return slot.Order
+	}
+	if Config.Random {
+		return ScheduleOrderShuffle
+	}
+	return ScheduleOrderSequential
+}
+
+// ActiveScheduleSlot returns the slot on air at t; the first matching slot wins
+func ActiveScheduleSlot(t time.Time) (*ScheduleSlot, *ScheduleOccurrence) {
+	for i := range Config.Schedule {
+		slot := &Config.Schedule[i]
+		// A slot that wraps past midnight may have started yesterday
+		for _, day := range []time.Time{t.AddDate(0, 0, -1), t} {
+			start, end, ok := slot.occursOn(day)
+			if ok && !t.Before(start) && t.Before(end) {
+				occurrence := slot.occurrence(start, end)
+				return slot, &occurrence
+			}
+		}
+	}
+	return nil, nil
+}
+
+// UpcomingScheduleSlots returns up to limit slot airings starting after t, soonest first
+func UpcomingScheduleSlots(t time.Time, limit int) []ScheduleOccurrence {
+	upcoming := []ScheduleOccurrence{}
+	for i := range Config.Schedule {
+		slot := &Config.Schedule[i]
+		for offset := 0; offset <= 7; offset++ {
+			start, end, ok := slot.occursOn(t.AddDate(0, 0, offset))
+			if ok && start.After(t) {
+				upcoming = append(upcoming, slot.occurrence(start, end))
+			}
+		}
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return upcoming[i].Start < upcoming[j].Start
+	})
+	if len(upcoming) > limit {
+		upcoming = upcoming[:limit]
+	}
+	return upcoming
+}
+
+// resolveSourcePath resolves a folder or playlist source relative to the music directory
+func resolveSourcePath(source string) string {
+	if filepath.IsAbs(source) {
+		return source
+	}
+	return filepath.Join(Config.Directory, source)
+}
+
+// SourceSongHashes returns the song hashes of a folder, M3U, named playlist ("playlist:NAME")
+// or smart playlist ("smart:NAME") source in playback order
+// Songs outside the music directory are registered in SongHashMap so they can be found by hash
+func SourceSongHashes(source string) ([]string, error) {
+	if strings.HasPrefix(source, playlistSourcePrefix) {
+		hashes, err := Playlists.SongHashes(strings.TrimPrefix(source, playlistSourcePrefix))
+		if err == nil && len(hashes) == 0 {
+			err = fmt.Errorf("no playable songs in %s", source)
+		}
+		return hashes, err
+	}
+	if strings.HasPrefix(source, smartSourcePrefix) {
+		hashes, err := SmartPlaylistSongHashes(strings.TrimPrefix(source, smartSourcePrefix))
+		if err == nil && len(hashes) == 0 {
+			err = fmt.Errorf("no songs match %s", source)
+		}
+		return hashes, err
+	}
+
+	path := resolveSourcePath(source)
+
+	var paths []string
+	var err error
+	if strings.HasSuffix(strings.ToLower(path), ".m3u") || strings.HasSuffix(strings.ToLower(path), ".m3u8") {
+		paths, err = ReadM3U(path)
+	} else if indexed, ok := Library.PathsUnder(path); ok {
+		paths = indexed
+	} else {
+		paths, err = scanMp3Files(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no mp3 files found in %s", source)
+	}
+
+	hashes := make([]string, len(paths))
+	for i, songPath := range paths {
+		hashes[i] = Library.HashForPath(songPath)
+		SongHashMap.Store(hashes[i], songPath)
+	}
+	return hashes, nil
+}
+
+// ReadM3U reads the MP3 entries of an M3U playlist; relative entries are resolved against the playlist's folder
+func ReadM3U(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePlaylist(data, PlaylistFormatM3U, filepath.Dir(path))
+}
+
+// scheduledNextHash picks the next song from the source of a schedule slot
+func scheduledNextHash(slot *ScheduleSlot) (string, bool) {
+	hashes, err := SourceSongHashes(slot.Source)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Schedule slot %s unavailable, using the library: %v", slot.Name, err))
+		return "", false
+	}
+
+	scheduleState.mu.Lock()
+	defer scheduleState.mu.Unlock()
+
+	next := pickNextHash("schedule:"+slot.Name, hashes, scheduleState.last[slot.Name], slot.order() == ScheduleOrderShuffle)
+	scheduleState.last[slot.Name] = next
+	return next, true
+}
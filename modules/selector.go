@@ -0,0 +1,158 @@
+package modules
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrackSelector picks automation songs when nothing is queued.
+// Selectors are consulted in priority order; the first one with something to offer wins.
+type TrackSelector interface {
+	// Name identifies the selector in logs and the API
+	Name() string
+	// Key identifies what the selector would pick for at now, e.g. a schedule slot.
+	// An empty key means the selector is not active. A cached pick whose key no
+	// longer matches is replaced when its turn comes.
+	Key(now time.Time) string
+	// Next picks the next song; ok is false when the selector cannot provide one
+	Next(now time.Time) (hash string, ok bool)
+}
+
+type registeredSelector struct {
+	priority int
+	selector TrackSelector
+}
+
+// Selector priorities of the built-in selectors
+const (
+	SelectorPriorityClock    = 200
+	SelectorPrioritySchedule = 100
+	SelectorPriorityLibrary  = 0
+)
+
+var trackSelectors = struct {
+	mu        sync.RWMutex
+	selectors []registeredSelector
+}{}
+
+// RegisterTrackSelector adds a selector; higher priorities are consulted first
+func RegisterTrackSelector(priority int, selector TrackSelector) {
+	trackSelectors.mu.Lock()
+	defer trackSelectors.mu.Unlock()
+
+	trackSelectors.selectors = append(trackSelectors.selectors, registeredSelector{priority, selector})
+	sort.SliceStable(trackSelectors.selectors, func(i, j int) bool {
+		return trackSelectors.selectors[i].priority > trackSelectors.selectors[j].priority
+	})
+}
+
+func init() {
+	RegisterTrackSelector(SelectorPriorityClock, ClockWheel)
+	RegisterTrackSelector(SelectorPrioritySchedule, scheduleSelector{})
+	RegisterTrackSelector(SelectorPriorityLibrary, librarySelector{})
+}
+
+// activeSelectors returns the registered selectors in priority order
+func activeSelectors() []TrackSelector {
+	trackSelectors.mu.RLock()
+	defer trackSelectors.mu.RUnlock()
+
+	selectors := make([]TrackSelector, len(trackSelectors.selectors))
+	for i, registered := range trackSelectors.selectors {
+		selectors[i] = registered.selector
+	}
+	return selectors
+}
+
+// Joins the keys of the selectors consulted for a pick
+const selectionKeySeparator = " > "
+
+// SelectTrack asks the selectors in priority order for the next song.
+// Returns the hash and the selection key: the keys of the active selectors that
+// were consulted, ending with the one that picked the song.
+func SelectTrack(now time.Time) (string, string) {
+	consulted := []string{}
+	for _, selector := range activeSelectors() {
+		key := selector.Key(now)
+		if key == "" {
+			continue
+		}
+		consulted = append(consulted, key)
+		if hash, ok := selector.Next(now); ok {
+			return hash, strings.Join(consulted, selectionKeySeparator)
+		}
+	}
+	return "", ""
+}
+
+// SelectionCurrent reports whether a selection key of SelectTrack still holds at now:
+// the selector that picked the song has the same key and no selector above it
+// became active or changed. A pick made by a fallback selector stays current
+// while the selectors it fell back from are unchanged.
+func SelectionCurrent(selection string, now time.Time) bool {
+	if selection == "" {
+		return false
+	}
+	consulted := strings.Count(selection, selectionKeySeparator) + 1
+	active := []string{}
+	for _, selector := range activeSelectors() {
+		if key := selector.Key(now); key != "" {
+			active = append(active, key)
+			if len(active) == consulted {
+				break
+			}
+		}
+	}
+	return strings.Join(active, selectionKeySeparator) == selection
+}
+
+// ActiveSelectorKey returns the key of the highest priority active selector
+func ActiveSelectorKey(now time.Time) string {
+	for _, selector := range activeSelectors() {
+		if key := selector.Key(now); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// librarySelector plays the whole music directory in random or sequential order
+type librarySelector struct{}
+
+func (librarySelector) Name() string {
+	return "library"
+}
+
+func (librarySelector) Key(now time.Time) string {
+	return "library"
+}
+
+func (librarySelector) Next(now time.Time) (string, bool) {
+	hash := MusicReader.GetNextMusicHash(SortedSongHashes)
+	return hash, hash != ""
+}
+
+// scheduleSelector plays the source of the active schedule slot
+type scheduleSelector struct{}
+
+func (scheduleSelector) Name() string {
+	return "schedule"
+}
+
+func (scheduleSelector) Key(now time.Time) string {
+	slot, _ := ActiveScheduleSlot(now)
+	if slot == nil || slot.Source == "" {
+		return ""
+	}
+	return "schedule:" + slot.Name
+}
+
+func (scheduleSelector) Next(now time.Time) (string, bool) {
+	slot, _ := ActiveScheduleSlot(now)
+	if slot == nil || slot.Source == "" {
+		return "", false
+	}
+	return scheduledNextHash(slot)
+}
@@ -0,0 +1,179 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum number of recent plays kept for separation checks
+const maxRecentPlays = 2000
+
+type recentPlay struct {
+	Hash string
+	Time time.Time
+}
+
+// Recent on-air songs, oldest first
+var recentPlays = struct {
+	mu    sync.Mutex
+	plays []recentPlay
+}{}
+
+// Separation windows are scaled down in these steps when no song satisfies them.
+// The last step only keeps the current song from playing twice in a row.
+var separationRelaxSteps = []float64{1, 0.5, 0.25, 0}
+
+func init() {
+	AddOnAirListener(recordRecentPlay)
+}
+
+func recordRecentPlay(item OnAirItem) {
+	if item.Type != OnAirTrack || item.Hash == "" {
+		return
+	}
+
+	recentPlays.mu.Lock()
+	defer recentPlays.mu.Unlock()
+
+	recentPlays.plays = append(recentPlays.plays, recentPlay{Hash: item.Hash, Time: time.UnixMilli(item.Time)})
+	if len(recentPlays.plays) > maxRecentPlays {
+		recentPlays.plays = recentPlays.plays[len(recentPlays.plays)-maxRecentPlays:]
+	}
+	// Kept across restarts so separation rules still apply after resuming
+	if err := SaveJSON(recentPlaysFile, recentPlays.plays); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save recent plays: %v", err))
+	}
+}
+
+// renameRecentPlays moves the recent plays of a song to its new hash
+func renameRecentPlays(oldHash, newHash string) {
+	recentPlays.mu.Lock()
+	defer recentPlays.mu.Unlock()
+
+	changed := false
+	for i := range recentPlays.plays {
+		if recentPlays.plays[i].Hash == oldHash {
+			recentPlays.plays[i].Hash = newHash
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := SaveJSON(recentPlaysFile, recentPlays.plays); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save recent plays: %v", err))
+	}
+}
+
+// recentPlaysSnapshot returns the recent plays including the song being started,
+// which is picked before it is announced on air
+func recentPlaysSnapshot(now time.Time) []recentPlay {
+	recentPlays.mu.Lock()
+	plays := make([]recentPlay, len(recentPlays.plays), len(recentPlays.plays)+1)
+	copy(plays, recentPlays.plays)
+	recentPlays.mu.Unlock()
+
+	current := MusicReader.CurrentSongHash
+	if current != "" && (len(plays) == 0 || plays[len(plays)-1].Hash != current) {
+		plays = append(plays, recentPlay{Hash: current, Time: now})
+	}
+	return plays
+}
+
+func separationEnabled() bool {
+	return Config.TrackSeparationHours > 0 || Config.ArtistSeparationMinutes > 0 || Config.AlbumSeparationTracks > 0
+}
+
+// sameTag compares artist or album tags, ignoring unknown values
+func sameTag(a, b string) bool {
+	if a == "" || b == "" || strings.EqualFold(a, "Unknown") {
+		return false
+	}
+	return strings.EqualFold(a, b)
+}
+
+// violatesSeparation reports whether playing hash now breaks a separation window scaled by factor
+func violatesSeparation(hash string, plays []recentPlay, factor float64, now time.Time) bool {
+	trackWindow := time.Duration(float64(Config.TrackSeparationHours) * factor * float64(time.Hour))
+	artistWindow := time.Duration(float64(Config.ArtistSeparationMinutes) * factor * float64(time.Minute))
+	albumTracks := int(float64(Config.AlbumSeparationTracks) * factor)
+
+	var meta TrackMeta
+	if artistWindow > 0 || albumTracks > 0 {
+		meta, _ = GetTrackMeta(hash)
+	}
+
+	for i := len(plays) - 1; i >= 0; i-- {
+		play := plays[i]
+		age := now.Sub(play.Time)
+		tracksAgo := len(plays) - 1 - i
+
+		inTrackWindow := age < trackWindow
+		inArtistWindow := age < artistWindow
+		inAlbumWindow := tracksAgo < albumTracks
+		if !inTrackWindow && !inArtistWindow && !inAlbumWindow {
+			// Plays are in chronological order, so older ones are outside every window too
+			break
+		}
+
+		if inTrackWindow && play.Hash == hash {
+			return true
+		}
+		if !inArtistWindow && !inAlbumWindow {
+			continue
+		}
+		playMeta, ok := GetTrackMeta(play.Hash)
+		if !ok {
+			continue
+		}
+		if inArtistWindow && sameTag(meta.Artist, playMeta.Artist) {
+			return true
+		}
+		if inAlbumWindow && sameTag(meta.Album, playMeta.Album) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickSeparatedHash picks a random (or weighted) song that respects the separation rules.
+// When no song qualifies (e.g. in a small library) the windows are relaxed step by step.
+func pickSeparatedHash(songHashes []string) string {
+	if len(songHashes) == 1 {
+		return songHashes[0]
+	}
+
+	now := time.Now()
+	current := MusicReader.CurrentSongHash
+	var plays []recentPlay
+	if separationEnabled() {
+		plays = recentPlaysSnapshot(now)
+	}
+
+	for _, factor := range separationRelaxSteps {
+		candidates := make([]string, 0, len(songHashes))
+		for _, hash := range songHashes {
+			if hash == current {
+				continue
+			}
+			if factor > 0 && plays != nil && violatesSeparation(hash, plays, factor, now) {
+				continue
+			}
+			candidates = append(candidates, hash)
+		}
+
+		if len(candidates) > 0 {
+			if factor < 1 && plays != nil {
+				Logger.Debug(fmt.Sprintf("Separation relaxed to %.0f%% (%d candidates)", factor*100, len(candidates)))
+			}
+			return pickRandomHash(candidates)
+		}
+		if plays == nil {
+			break
+		}
+	}
+
+	return pickRandomHash(songHashes)
+}
@@ -0,0 +1,435 @@
+package modules
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix of sources that refer to a smart playlist
+const smartSourcePrefix = "smart:"
+
+// How long the matches of a smart playlist are reused; time rules such as
+// `added in last 7 days` change without anything else changing
+const smartPlaylistCacheTTL = time.Minute
+
+type smartPlaylistMatches struct {
+	hashes    []string
+	version   int64
+	evaluated time.Time
+}
+
+// Evaluated smart playlists by name. The version changes with the library,
+// play counts and ratings, which drops every cached evaluation.
+var smartPlaylistCache = struct {
+	mu      sync.Mutex
+	version int64
+	matches map[string]smartPlaylistMatches
+}{
+	matches: make(map[string]smartPlaylistMatches),
+}
+
+// SmartPlaylist is a dynamic playlist defined by a rule evaluated against the library, e.g.
+// `genre = Jazz AND year < 1970`, `added in last 30 days` or `play_count < 3`
+type SmartPlaylist struct {
+	Rule  string `json:"rule"`
+	Sort  string `json:"sort"`  // Field to sort matches by, "-" prefix for descending; empty keeps library order
+	Limit int    `json:"limit"` // Keep only the first matches after sorting (0 = all)
+	Order string `json:"order"` // "shuffle" or "sequential" in rotation; empty follows the global random flag
+
+	rule SmartRule
+}
+
+// SmartRule is a parsed rule: any of the groups matches when all of its conditions match
+type SmartRule [][]smartCondition
+
+type smartCondition struct {
+	field  string
+	op     string
+	text   string        // Lower-cased value for text fields
+	number float64       // Value for number fields
+	within time.Duration // Window for "in last" conditions
+}
+
+// Rule fields by kind
+var (
+	smartTextFields   = map[string]bool{"title": true, "artist": true, "album": true, "genre": true, "path": true}
+	smartNumberFields = map[string]bool{"year": true, "play_count": true, "likes": true, "dislikes": true}
+	smartTimeFields   = map[string]bool{"added": true, "played": true}
+)
+
+var smartTokenPattern = regexp.MustCompile(`"[^"]*"|'[^']*'|<=|>=|!=|=|<|>|[^\s<>=!"']+`)
+
+var smartUnits = map[string]time.Duration{
+	"minute": time.Minute, "minutes": time.Minute,
+	"hour": time.Hour, "hours": time.Hour,
+	"day": 24 * time.Hour, "days": 24 * time.Hour,
+	"week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// ParseSmartRule parses conditions joined by AND and OR (AND binds tighter).
+// Conditions are `field op value` with op one of = != < <= > >= contains,
+// or `field [not] in last N days|hours|weeks` for added and played.
+func ParseSmartRule(rule string) (SmartRule, error) {
+	tokens := smartTokenPattern.FindAllString(rule, -1)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+
+	var parsed SmartRule
+	var group []smartCondition
+	var current []string
+	flush := func() error {
+		if len(current) == 0 {
+			return fmt.Errorf("missing condition in %q", rule)
+		}
+		condition, err := parseSmartCondition(current)
+		if err != nil {
+			return err
+		}
+		group = append(group, condition)
+		current = nil
+		return nil
+	}
+
+	for _, token := range tokens {
+		switch strings.ToUpper(token) {
+		case "AND":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case "OR":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, group)
+			group = nil
+		default:
+			current = append(current, token)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return append(parsed, group), nil
+}
+
+func unquote(token string) string {
+	if len(token) >= 2 && (token[0] == '"' || token[0] == '\'') && token[len(token)-1] == token[0] {
+		return token[1 : len(token)-1]
+	}
+	return token
+}
+
+func parseSmartCondition(tokens []string) (smartCondition, error) {
+	condition := smartCondition{field: strings.ToLower(tokens[0])}
+	if !smartTextFields[condition.field] && !smartNumberFields[condition.field] && !smartTimeFields[condition.field] {
+		return condition, fmt.Errorf("unknown field %q", tokens[0])
+	}
+	if len(tokens) < 2 {
+		return condition, fmt.Errorf("missing operator after %q", tokens[0])
+	}
+
+	rest := tokens[1:]
+	words := strings.ToLower(strings.Join(rest, " "))
+
+	// added / played [not] in last N unit
+	if smartTimeFields[condition.field] {
+		condition.op = "in last"
+		if strings.HasPrefix(words, "not ") {
+			condition.op = "not in last"
+			rest = rest[1:]
+		}
+		if len(rest) != 4 || strings.ToLower(rest[0]) != "in" || strings.ToLower(rest[1]) != "last" {
+			return condition, fmt.Errorf("%s supports \"in last N days\" and \"not in last N days\"", condition.field)
+		}
+		count, err := strconv.ParseFloat(rest[2], 64)
+		unit, ok := smartUnits[strings.ToLower(rest[3])]
+		if err != nil || count <= 0 || !ok {
+			return condition, fmt.Errorf("invalid window %q (use e.g. 30 days, 12 hours, 2 weeks)", rest[2]+" "+rest[3])
+		}
+		condition.within = time.Duration(count * float64(unit))
+		return condition, nil
+	}
+
+	condition.op = strings.ToLower(rest[0])
+	value := rest[1:]
+	if condition.op == "not" && len(rest) > 1 && strings.ToLower(rest[1]) == "contains" {
+		condition.op = "not contains"
+		value = rest[2:]
+	}
+	if len(value) == 0 {
+		return condition, fmt.Errorf("missing value for %s %s", condition.field, condition.op)
+	}
+	for i := range value {
+		value[i] = unquote(value[i])
+	}
+	text := strings.Join(value, " ")
+
+	if smartTextFields[condition.field] {
+		switch condition.op {
+		case "=", "!=", "contains", "not contains":
+		default:
+			return condition, fmt.Errorf("%s supports =, !=, contains and not contains", condition.field)
+		}
+		condition.text = strings.ToLower(text)
+		return condition, nil
+	}
+
+	switch condition.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+	default:
+		return condition, fmt.Errorf("%s supports =, !=, <, <=, > and >=", condition.field)
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return condition, fmt.Errorf("%s needs a number, got %q", condition.field, text)
+	}
+	condition.number = number
+	return condition, nil
+}
+
+// smartSong holds the values rules are evaluated against
+type smartSong struct {
+	hash   string
+	meta   TrackMeta
+	path   string // Relative to the music directory
+	added  time.Time
+	plays  PlayCount
+	rating TrackRating
+}
+
+func loadSmartSong(hash string) (smartSong, bool) {
+	entry, ok := Library.Entry(hash)
+	if !ok {
+		return smartSong{}, false
+	}
+	path := entry.Path
+	meta, _ := GetTrackMeta(hash)
+
+	relative := path
+	if rel, err := filepath.Rel(Config.Directory, path); err == nil {
+		relative = filepath.ToSlash(rel)
+	}
+	return smartSong{
+		hash:   hash,
+		meta:   meta,
+		path:   relative,
+		added:  time.UnixMilli(entry.FirstSeen),
+		plays:  PlayCounts.Get(hash),
+		rating: TrackRatings.Get(hash),
+	}, true
+}
+
+func (song smartSong) text(field string) string {
+	switch field {
+	case "title":
+		return song.meta.Title
+	case "artist":
+		return song.meta.Artist
+	case "album":
+		return song.meta.Album
+	case "genre":
+		return song.meta.Genre
+	case "path":
+		return song.path
+	}
+	return ""
+}
+
+func (song smartSong) number(field string) float64 {
+	switch field {
+	case "year":
+		return float64(song.meta.Year)
+	case "play_count":
+		return float64(song.plays.Count)
+	case "likes":
+		return float64(song.rating.Likes)
+	case "dislikes":
+		return float64(song.rating.Dislikes)
+	}
+	return 0
+}
+
+func (song smartSong) time(field string) time.Time {
+	if field == "played" {
+		if song.plays.LastPlayed == 0 {
+			return time.Time{}
+		}
+		return time.UnixMilli(song.plays.LastPlayed)
+	}
+	return song.added
+}
+
+func (condition smartCondition) matches(song smartSong, now time.Time) bool {
+	if smartTimeFields[condition.field] {
+		t := song.time(condition.field)
+		within := !t.IsZero() && now.Sub(t) <= condition.within
+		return within == (condition.op == "in last")
+	}
+
+	if smartTextFields[condition.field] {
+		value := strings.ToLower(song.text(condition.field))
+		switch condition.op {
+		case "=":
+			return value == condition.text
+		case "!=":
+			return value != condition.text
+		case "contains":
+			return strings.Contains(value, condition.text)
+		case "not contains":
+			return !strings.Contains(value, condition.text)
+		}
+		return false
+	}
+
+	value := song.number(condition.field)
+	switch condition.op {
+	case "=":
+		return value == condition.number
+	case "!=":
+		return value != condition.number
+	case "<":
+		return value < condition.number
+	case "<=":
+		return value <= condition.number
+	case ">":
+		return value > condition.number
+	case ">=":
+		return value >= condition.number
+	}
+	return false
+}
+
+// Matches reports whether a song satisfies the rule
+func (rule SmartRule) matches(song smartSong, now time.Time) bool {
+	for _, group := range rule {
+		all := true
+		for _, condition := range group {
+			if !condition.matches(song, now) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+func validSmartSort(field string) bool {
+	field = strings.TrimPrefix(field, "-")
+	return field == "" || smartTextFields[field] || smartNumberFields[field] || smartTimeFields[field]
+}
+
+// PrepareSmartPlaylists parses the rules of the smart playlists
+func PrepareSmartPlaylists(playlists map[string]SmartPlaylist) error {
+	for name, playlist := range playlists {
+		rule, err := ParseSmartRule(playlist.Rule)
+		if err != nil {
+			return fmt.Errorf("smart playlist %s: %v", name, err)
+		}
+		playlist.rule = rule
+		playlist.Sort = strings.ToLower(playlist.Sort)
+		if !validSmartSort(playlist.Sort) {
+			return fmt.Errorf("smart playlist %s: unknown sort field %q", name, playlist.Sort)
+		}
+		playlist.Order = strings.ToLower(playlist.Order)
+		if playlist.Order != "" && playlist.Order != ScheduleOrderShuffle && playlist.Order != ScheduleOrderSequential {
+			return fmt.Errorf("smart playlist %s: order must be shuffle or sequential", name)
+		}
+		if playlist.Limit < 0 {
+			return fmt.Errorf("smart playlist %s: limit must not be negative", name)
+		}
+		playlists[name] = playlist
+	}
+	return nil
+}
+
+// Evaluate returns the library songs matching the smart playlist, sorted and limited
+func (playlist SmartPlaylist) Evaluate(now time.Time) []string {
+	if _, err := GetMp3FilePaths(); err != nil {
+		Logger.Error(err)
+		return []string{}
+	}
+
+	songs := []smartSong{}
+	for _, hash := range SortedSongHashes {
+		if song, ok := loadSmartSong(hash); ok && playlist.rule.matches(song, now) {
+			songs = append(songs, song)
+		}
+	}
+
+	if playlist.Sort != "" {
+		field := strings.TrimPrefix(playlist.Sort, "-")
+		descending := strings.HasPrefix(playlist.Sort, "-")
+		sort.SliceStable(songs, func(i, j int) bool {
+			a, b := songs[i], songs[j]
+			if descending {
+				a, b = b, a
+			}
+			switch {
+			case smartTextFields[field]:
+				return strings.ToLower(a.text(field)) < strings.ToLower(b.text(field))
+			case smartNumberFields[field]:
+				return a.number(field) < b.number(field)
+			default:
+				return a.time(field).Before(b.time(field))
+			}
+		})
+	}
+	if playlist.Limit > 0 && len(songs) > playlist.Limit {
+		songs = songs[:playlist.Limit]
+	}
+
+	hashes := make([]string, len(songs))
+	for i, song := range songs {
+		hashes[i] = song.hash
+	}
+	return hashes
+}
+
+// shuffle reports whether the smart playlist plays in random order
+func (playlist SmartPlaylist) shuffle() bool {
+	if playlist.Order != "" {
+		return playlist.Order == ScheduleOrderShuffle
+	}
+	return Config.Random
+}
+
+// invalidateSmartPlaylists makes the next rotation pick evaluate smart playlists again
+func invalidateSmartPlaylists() {
+	smartPlaylistCache.mu.Lock()
+	defer smartPlaylistCache.mu.Unlock()
+	smartPlaylistCache.version++
+}
+
+// SmartPlaylistSongHashes returns the current matches of a configured smart playlist.
+// Matches are cached until the songs change or for smartPlaylistCacheTTL.
+func SmartPlaylistSongHashes(name string) ([]string, error) {
+	playlist, ok := Config.SmartPlaylists[name]
+	if !ok {
+		return nil, fmt.Errorf("smart playlist not found")
+	}
+
+	now := time.Now()
+	smartPlaylistCache.mu.Lock()
+	version := smartPlaylistCache.version
+	cached, ok := smartPlaylistCache.matches[name]
+	smartPlaylistCache.mu.Unlock()
+	if ok && cached.version == version && now.Sub(cached.evaluated) < smartPlaylistCacheTTL {
+		return append([]string{}, cached.hashes...), nil
+	}
+
+	hashes := playlist.Evaluate(now)
+	smartPlaylistCache.mu.Lock()
+	smartPlaylistCache.matches[name] = smartPlaylistMatches{hashes: hashes, version: version, evaluated: now}
+	smartPlaylistCache.mu.Unlock()
+	return append([]string{}, hashes...), nil
+}
@@ -0,0 +1,177 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// ErrSongNotFound is returned for hashes that are not in the library
+var ErrSongNotFound = errors.New("song not found")
+
+// TagUpdate lists the tags to change; nil fields are left as they are and
+// empty strings remove the tag
+type TagUpdate struct {
+	Title         *string
+	Artist        *string
+	Album         *string
+	Genre         *string
+	Year          *string
+	Artwork       []byte // New front cover
+	RemoveArtwork bool
+}
+
+// librarySong returns the path of a song of the library
+func librarySong(hash string) (string, error) {
+	entry, ok := Library.Entry(hash)
+	if !ok {
+		return "", ErrSongNotFound
+	}
+	return entry.Path, nil
+}
+
+// EditSongTags writes ID3 tags of a song and re-indexes it
+func EditSongTags(hash string, update TagUpdate) (LibraryEntry, error) {
+	path, err := librarySong(hash)
+	if err != nil {
+		return LibraryEntry{}, err
+	}
+	if update.Year != nil && *update.Year != "" {
+		if year, err := strconv.Atoi(*update.Year); err != nil || year < 0 || year > 9999 {
+			return LibraryEntry{}, fmt.Errorf("year must be a number")
+		}
+	}
+	mimeType := ""
+	if update.Artwork != nil {
+		mimeType = http.DetectContentType(update.Artwork)
+		if mimeType != "image/jpeg" && mimeType != "image/png" {
+			return LibraryEntry{}, fmt.Errorf("artwork must be a JPEG or PNG image")
+		}
+	}
+
+	tag, err := id3v2.Open(path, id3v2.Options{Parse: true})
+	if err != nil {
+		return LibraryEntry{}, err
+	}
+	defer tag.Close()
+
+	// ID3v2.3 has no UTF-8; UTF-16 keeps non-Latin characters
+	encoding := id3v2.EncodingUTF16
+	if tag.Version() == 4 {
+		encoding = id3v2.EncodingUTF8
+	}
+	setText := func(description string, value *string) {
+		if value == nil {
+			return
+		}
+		id := tag.CommonID(description)
+		tag.DeleteFrames(id)
+		if text := strings.TrimSpace(*value); text != "" {
+			tag.AddTextFrame(id, encoding, text)
+		}
+	}
+	setText("Title", update.Title)
+	setText("Artist", update.Artist)
+	setText("Album/Movie/Show title", update.Album)
+	setText("Content type", update.Genre)
+	setText("Year", update.Year)
+
+	if update.RemoveArtwork || update.Artwork != nil {
+		tag.DeleteFrames(tag.CommonID("Attached picture"))
+	}
+	if update.Artwork != nil {
+		tag.AddAttachedPicture(id3v2.PictureFrame{
+			Encoding:    encoding,
+			MimeType:    mimeType,
+			PictureType: id3v2.PTFrontCover,
+			Description: "Front cover",
+			Picture:     update.Artwork,
+		})
+	}
+
+	if err := tag.Save(); err != nil {
+		return LibraryEntry{}, err
+	}
+	Logger.Info(fmt.Sprintf("Edited tags of %s", path))
+
+	// The transcoded copy carries the old tags
+	removeTranscoded(path)
+	if _, err := Library.Update([]string{path}); err != nil {
+		return LibraryEntry{}, err
+	}
+	entry, _ := Library.Entry(hash)
+	return entry, nil
+}
+
+// MoveSong renames or moves a song within the music directory.
+// target is relative to the music directory; the song keeps its hash and
+// named playlists follow it to its new path.
+func MoveSong(hash, target string) (LibraryEntry, error) {
+	path, err := librarySong(hash)
+	if err != nil {
+		return LibraryEntry{}, err
+	}
+
+	target = filepath.FromSlash(strings.TrimSpace(target))
+	if !strings.HasSuffix(strings.ToLower(target), ".mp3") {
+		target += ".mp3"
+	}
+	if !filepath.IsLocal(target) {
+		return LibraryEntry{}, fmt.Errorf("target must be a path inside the music directory")
+	}
+	newPath := filepath.Join(Config.Directory, target)
+	if newPath == path {
+		entry, _ := Library.Entry(hash)
+		return entry, nil
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return LibraryEntry{}, fmt.Errorf("target already exists")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return LibraryEntry{}, err
+	}
+	if err := os.Rename(path, newPath); err != nil {
+		return LibraryEntry{}, err
+	}
+	Logger.Info(fmt.Sprintf("Moved %s to %s", path, newPath))
+	removeTranscoded(path)
+
+	// Both paths in one update, so the song keeps its hash and named playlists follow it
+	if _, err := Library.Update([]string{newPath, path}); err != nil {
+		return LibraryEntry{}, err
+	}
+	entry, _ := Library.Entry(hash)
+	return entry, nil
+}
+
+// DeleteSong deletes a song file; it leaves the queue, open requests and the library
+func DeleteSong(hash string) error {
+	path, err := librarySong(hash)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	Logger.Info(fmt.Sprintf("Deleted %s", path))
+	removeTranscoded(path)
+
+	_, err = Library.Update([]string{path})
+	return err
+}
+
+// removeTranscoded deletes the cached transcoded copy of a song
+func removeTranscoded(path string) {
+	cachedPath := GetCachedPath(path)
+	if err := os.Remove(cachedPath); err != nil && !os.IsNotExist(err) {
+		Logger.Error(fmt.Sprintf("Failed to remove cached copy of %s: %v", path, err))
+	}
+	TranscodeCache.forget([]string{filepath.Base(cachedPath)})
+}
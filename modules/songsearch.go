@@ -0,0 +1,108 @@
+package modules
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SongQuery filters and sorts the library for the songs list
+type SongQuery struct {
+	Text     string // Words that must all appear in the title, artist, album or file name
+	Genre    string // Case-insensitive
+	YearFrom int    // 0 = no lower bound
+	YearTo   int    // 0 = no upper bound
+	Folder   string // Relative to the music directory
+	Sort     string // Field, "-" prefix for descending; empty keeps library order
+}
+
+// Fields the songs list can be sorted by
+var songSortFields = map[string]bool{
+	"title": true, "artist": true, "album": true, "genre": true, "year": true,
+	"duration": true, "bitrate": true, "added": true, "path": true,
+	"play_count": true, "last_played": true,
+}
+
+// SongMatch is a library song with its play statistics
+type SongMatch struct {
+	LibraryEntry
+	Plays PlayCount
+}
+
+// SearchSongs returns the library songs matching the query in the requested order
+func SearchSongs(query SongQuery) ([]SongMatch, error) {
+	field := strings.ToLower(strings.TrimPrefix(query.Sort, "-"))
+	if field != "" && !songSortFields[field] {
+		return nil, fmt.Errorf("unknown sort field %q", field)
+	}
+	descending := strings.HasPrefix(query.Sort, "-")
+
+	words := strings.Fields(strings.ToLower(query.Text))
+	genre := strings.ToLower(strings.TrimSpace(query.Genre))
+	folder := ""
+	if query.Folder != "" {
+		folder = filepath.Join(Config.Directory, filepath.FromSlash(query.Folder)) + string(filepath.Separator)
+	}
+
+	counts := PlayCounts.All()
+	matches := []SongMatch{}
+	for _, entry := range Library.Entries() {
+		if genre != "" && strings.ToLower(entry.Genre) != genre {
+			continue
+		}
+		if (query.YearFrom > 0 && entry.Year < query.YearFrom) || (query.YearTo > 0 && entry.Year > query.YearTo) {
+			continue
+		}
+		if folder != "" && !strings.HasPrefix(entry.Path, folder) {
+			continue
+		}
+		if len(words) > 0 {
+			text := strings.ToLower(strings.Join([]string{entry.Title, entry.Artist, entry.Album, filepath.Base(entry.Path)}, " "))
+			found := true
+			for _, word := range words {
+				if !strings.Contains(text, word) {
+					found = false
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		matches = append(matches, SongMatch{LibraryEntry: entry, Plays: counts[entry.Hash]})
+	}
+
+	if field != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			a, b := matches[i], matches[j]
+			if descending {
+				a, b = b, a
+			}
+			switch field {
+			case "title":
+				return strings.ToLower(a.DisplayTitle()) < strings.ToLower(b.DisplayTitle())
+			case "artist":
+				return strings.ToLower(a.Artist) < strings.ToLower(b.Artist)
+			case "album":
+				return strings.ToLower(a.Album) < strings.ToLower(b.Album)
+			case "genre":
+				return strings.ToLower(a.Genre) < strings.ToLower(b.Genre)
+			case "year":
+				return a.Year < b.Year
+			case "duration":
+				return a.Duration < b.Duration
+			case "bitrate":
+				return a.Bitrate < b.Bitrate
+			case "added":
+				return a.FirstSeen < b.FirstSeen
+			case "play_count":
+				return a.Plays.Count < b.Plays.Count
+			case "last_played":
+				return a.Plays.LastPlayed < b.Plays.LastPlayed
+			}
+			return a.Path < b.Path
+		})
+	}
+	return matches, nil
+}
@@ -0,0 +1,498 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File in the data directory holding spot play counts
+const spotCountsFile = "spot_counts.json"
+
+// SpotConfig is a sponsor or ad spot with its booking window and play caps
+type SpotConfig struct {
+	Name           string `json:"name"`
+	File           string `json:"file"`              // MP3 file, relative to spot_dir
+	Start          string `json:"start"`             // First day the spot may air (YYYY-MM-DD, optional)
+	End            string `json:"end"`               // Last day the spot may air (YYYY-MM-DD, optional)
+	MaxPlays       int    `json:"max_plays"`         // Total play cap (0 = unlimited)
+	MaxPlaysPerDay int    `json:"max_plays_per_day"` // Daily play cap (0 = unlimited)
+
+	startDay time.Time
+	endDay   time.Time
+}
+
+// BreakConfig is a group of spots aired together
+type BreakConfig struct {
+	Name     string   `json:"name"`
+	Minutes  []int    `json:"minutes,omitempty"` // Minutes past the hour at which the break is due (optional)
+	Spots    []string `json:"spots"`             // Spot names in airing order
+	MaxSpots int      `json:"max_spots"`         // Maximum spots per break (0 = all eligible)
+}
+
+// SpotPlay is a proof-of-play log entry
+type SpotPlay struct {
+	Time      int64  `json:"time"` // Unix milliseconds
+	Spot      string `json:"spot"`
+	File      string `json:"file"`
+	Break     string `json:"break"`
+	Listeners int64  `json:"listeners"`
+}
+
+// SpotStatus describes a spot and its remaining plays
+type SpotStatus struct {
+	Name       string `json:"name"`
+	File       string `json:"file"`
+	Start      string `json:"start,omitempty"`
+	End        string `json:"end,omitempty"`
+	Plays      int    `json:"plays"`
+	PlaysToday int    `json:"plays_today"`
+	MaxPlays   int    `json:"max_plays"`
+	MaxPerDay  int    `json:"max_plays_per_day"`
+	Eligible   bool   `json:"eligible"`
+}
+
+type spotCounts struct {
+	Total map[string]int            `json:"total"`
+	Daily map[string]map[string]int `json:"daily"` // Day (YYYY-MM-DD) -> spot -> plays
+}
+
+// queuedSpot remembers which spot and break a queued hash belongs to
+type queuedSpot struct {
+	spot  string
+	brk   string
+	count int // Queued copies not yet on air
+}
+
+// IAdBreaks schedules spot breaks into the playlist queue and logs every spot that airs
+type IAdBreaks struct {
+	mu      sync.Mutex
+	loaded  bool
+	counts  spotCounts
+	queued  map[string]*queuedSpot // Spot hash -> pending break entry
+	lastDue map[string]time.Time   // Break name -> last scheduled time it was triggered for
+	logMu   sync.Mutex
+}
+
+var AdBreaks = &IAdBreaks{
+	queued:  make(map[string]*queuedSpot),
+	lastDue: make(map[string]time.Time),
+}
+
+// PrepareSpots validates spots and breaks
+func PrepareSpots(spots []SpotConfig, breaks []BreakConfig) error {
+	names := make(map[string]bool)
+	for i := range spots {
+		spot := &spots[i]
+		if spot.Name == "" || spot.File == "" {
+			return fmt.Errorf("every spot needs a name and a file")
+		}
+		if names[spot.Name] {
+			return fmt.Errorf("duplicate spot %s", spot.Name)
+		}
+		names[spot.Name] = true
+
+		var err error
+		if spot.Start != "" {
+			if spot.startDay, err = time.ParseInLocation("2006-01-02", spot.Start, time.Local); err != nil {
+				return fmt.Errorf("spot %s: invalid start date %q (use YYYY-MM-DD)", spot.Name, spot.Start)
+			}
+		}
+		if spot.End != "" {
+			if spot.endDay, err = time.ParseInLocation("2006-01-02", spot.End, time.Local); err != nil {
+				return fmt.Errorf("spot %s: invalid end date %q (use YYYY-MM-DD)", spot.Name, spot.End)
+			}
+		}
+	}
+
+	breakNames := make(map[string]bool)
+	for _, brk := range breaks {
+		if brk.Name == "" {
+			return fmt.Errorf("every break needs a name")
+		}
+		if breakNames[brk.Name] {
+			return fmt.Errorf("duplicate break %s", brk.Name)
+		}
+		breakNames[brk.Name] = true
+
+		for _, minute := range brk.Minutes {
+			if minute < 0 || minute > 59 {
+				return fmt.Errorf("break %s: minutes must be between 0 and 59", brk.Name)
+			}
+		}
+		for _, name := range brk.Spots {
+			if !names[name] {
+				return fmt.Errorf("break %s: unknown spot %q", brk.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	AddOnAirListener(AdBreaks.onAir)
+}
+
+func findSpot(name string) *SpotConfig {
+	for i := range Config.Spots {
+		if Config.Spots[i].Name == name {
+			return &Config.Spots[i]
+		}
+	}
+	return nil
+}
+
+func findBreak(name string) *BreakConfig {
+	for i := range Config.Breaks {
+		if Config.Breaks[i].Name == name {
+			return &Config.Breaks[i]
+		}
+	}
+	return nil
+}
+
+func spotPath(spot *SpotConfig) string {
+	if filepath.IsAbs(spot.File) {
+		return spot.File
+	}
+	return filepath.Join(Config.SpotDir, spot.File)
+}
+
+// loadLocked restores the play counts saved by a previous run
+func (a *IAdBreaks) loadLocked() {
+	if a.loaded {
+		return
+	}
+	a.loaded = true
+
+	if err := LoadJSON(spotCountsFile, &a.counts); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load spot play counts: %v", err))
+	}
+	if a.counts.Total == nil {
+		a.counts.Total = make(map[string]int)
+	}
+	if a.counts.Daily == nil {
+		a.counts.Daily = make(map[string]map[string]int)
+	}
+}
+
+// reconcileLocked forgets queued spots that left the reader's queue without airing
+// (cleared, removed or replaced). queue comes from MusicReader.queuedCounts, taken
+// before locking a.mu.
+func (a *IAdBreaks) reconcileLocked(queue map[string]int) {
+	for hash, entry := range a.queued {
+		if queue[hash] < entry.count {
+			entry.count = queue[hash]
+		}
+		if entry.count <= 0 {
+			delete(a.queued, hash)
+		}
+	}
+}
+
+// queuedLocked counts the queued copies of a spot that have not aired yet
+func (a *IAdBreaks) queuedLocked(name string) int {
+	count := 0
+	for _, entry := range a.queued {
+		if entry.spot == name {
+			count += entry.count
+		}
+	}
+	return count
+}
+
+// eligibleLocked reports whether a spot may air on the given day.
+// Queued copies count against the caps, as they air after the current song.
+func (a *IAdBreaks) eligibleLocked(spot *SpotConfig, now time.Time) bool {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !spot.startDay.IsZero() && day.Before(spot.startDay) {
+		return false
+	}
+	if !spot.endDay.IsZero() && day.After(spot.endDay) {
+		return false
+	}
+	queued := a.queuedLocked(spot.Name)
+	if spot.MaxPlays > 0 && a.counts.Total[spot.Name]+queued >= spot.MaxPlays {
+		return false
+	}
+	if spot.MaxPlaysPerDay > 0 && a.counts.Daily[now.Format("2006-01-02")][spot.Name]+queued >= spot.MaxPlaysPerDay {
+		return false
+	}
+	if _, err := os.Stat(spotPath(spot)); err != nil {
+		return false
+	}
+	return true
+}
+
+// IsSpot reports whether a hash belongs to a queued spot
+func (a *IAdBreaks) IsSpot(hash string) bool {
+	if hash == "" {
+		return false
+	}
+	queue := MusicReader.queuedCounts()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reconcileLocked(queue)
+	_, ok := a.queued[hash]
+	return ok
+}
+
+// TriggerBreak queues the eligible spots of a break to air after the current song
+// Returns the names of the queued spots
+func (a *IAdBreaks) TriggerBreak(name string) ([]string, error) {
+	brk := findBreak(name)
+	if brk == nil {
+		return nil, fmt.Errorf("unknown break %q", name)
+	}
+
+	now := time.Now()
+	queue := MusicReader.queuedCounts()
+	a.mu.Lock()
+	a.loadLocked()
+	a.reconcileLocked(queue)
+
+	var hashes, aired []string
+	for _, spotName := range brk.Spots {
+		if brk.MaxSpots > 0 && len(hashes) >= brk.MaxSpots {
+			break
+		}
+		spot := findSpot(spotName)
+		if spot == nil || !a.eligibleLocked(spot, now) {
+			continue
+		}
+
+		path := spotPath(spot)
+		hash := GenerateSongHash(path)
+		SongHashMap.Store(hash, path)
+
+		entry, ok := a.queued[hash]
+		if !ok {
+			entry = &queuedSpot{}
+			a.queued[hash] = entry
+		}
+		entry.spot = spot.Name
+		entry.brk = brk.Name
+		entry.count++
+
+		hashes = append(hashes, hash)
+		aired = append(aired, spot.Name)
+	}
+	// Queued under a.mu, so a reconcile never sees the spots before the reader does
+	if len(hashes) > 0 {
+		MusicReader.InsertBreak(hashes)
+	}
+	a.mu.Unlock()
+
+	if len(hashes) == 0 {
+		Logger.Info(fmt.Sprintf("Break %s skipped: no eligible spots", brk.Name))
+		return aired, nil
+	}
+	Logger.Info(fmt.Sprintf("Break %s queued: %s", brk.Name, strings.Join(aired, ", ")))
+	return aired, nil
+}
+
+// onAir writes proof of play when a queued spot goes on air
+func (a *IAdBreaks) onAir(item OnAirItem) {
+	if item.Hash == "" {
+		return
+	}
+
+	a.mu.Lock()
+	entry, ok := a.queued[item.Hash]
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	entry.count--
+	if entry.count <= 0 {
+		delete(a.queued, item.Hash)
+	}
+
+	a.loadLocked()
+	day := time.UnixMilli(item.Time).Format("2006-01-02")
+	a.counts.Total[entry.spot]++
+	if a.counts.Daily[day] == nil {
+		a.counts.Daily[day] = make(map[string]int)
+	}
+	a.counts.Daily[day][entry.spot]++
+	// Daily counts are only needed for today's cap
+	for key := range a.counts.Daily {
+		if key < time.Now().AddDate(0, 0, -1).Format("2006-01-02") {
+			delete(a.counts.Daily, key)
+		}
+	}
+	if err := SaveJSON(spotCountsFile, a.counts); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save spot play counts: %v", err))
+	}
+
+	spot := findSpot(entry.spot)
+	play := SpotPlay{
+		Time:      item.Time,
+		Spot:      entry.spot,
+		Break:     entry.brk,
+		Listeners: ActiveListenerCount(),
+	}
+	if spot != nil {
+		play.File = spot.File
+	}
+	a.mu.Unlock()
+
+	a.appendProofOfPlay(play)
+}
+
+// appendProofOfPlay adds an entry to the monthly proof-of-play log
+func (a *IAdBreaks) appendProofOfPlay(play SpotPlay) {
+	a.logMu.Lock()
+	defer a.logMu.Unlock()
+
+	if err := os.MkdirAll(Config.ProofOfPlayDir, 0755); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to create proof-of-play directory: %v", err))
+		return
+	}
+
+	line, err := json.Marshal(play)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+
+	month := time.UnixMilli(play.Time).Format("2006-01")
+	path := filepath.Join(Config.ProofOfPlayDir, fmt.Sprintf("pop-%s.jsonl", month))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Failed to open proof-of-play log: %v", err))
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to write proof-of-play log: %v", err))
+		return
+	}
+	Logger.Info(fmt.Sprintf("Spot aired: %s (break %s, %d listeners)", play.Spot, play.Break, play.Listeners))
+}
+
+// ProofOfPlay returns the spots aired in [from, to), optionally for one spot, oldest first
+func ProofOfPlay(from, to time.Time, spot string) ([]SpotPlay, error) {
+	plays := []SpotPlay{}
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	for month.Before(to) {
+		path := filepath.Join(Config.ProofOfPlayDir, fmt.Sprintf("pop-%s.jsonl", month.Format("2006-01")))
+		month = month.AddDate(0, 1, 0)
+
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var play SpotPlay
+			if err := json.Unmarshal(scanner.Bytes(), &play); err != nil {
+				continue
+			}
+			if play.Time < from.UnixMilli() || play.Time >= to.UnixMilli() {
+				continue
+			}
+			if spot != "" && play.Spot != spot {
+				continue
+			}
+			plays = append(plays, play)
+		}
+		file.Close()
+	}
+	return plays, nil
+}
+
+// Status returns every spot with its play counts and whether it can air today
+func (a *IAdBreaks) Status() []SpotStatus {
+	now := time.Now()
+	queue := MusicReader.queuedCounts()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loadLocked()
+	a.reconcileLocked(queue)
+
+	statuses := []SpotStatus{}
+	for i := range Config.Spots {
+		spot := &Config.Spots[i]
+		statuses = append(statuses, SpotStatus{
+			Name:       spot.Name,
+			File:       spot.File,
+			Start:      spot.Start,
+			End:        spot.End,
+			Plays:      a.counts.Total[spot.Name],
+			PlaysToday: a.counts.Daily[now.Format("2006-01-02")][spot.Name],
+			MaxPlays:   spot.MaxPlays,
+			MaxPerDay:  spot.MaxPlaysPerDay,
+			Eligible:   a.eligibleLocked(spot, now),
+		})
+	}
+	return statuses
+}
+
+// dueBreaks returns the breaks whose scheduled minute passed since they were last triggered
+func (a *IAdBreaks) dueBreaks(now time.Time) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	due := []string{}
+	for _, brk := range Config.Breaks {
+		// Latest scheduled time at or before now
+		var latest time.Time
+		for _, minute := range brk.Minutes {
+			scheduled := now.Truncate(time.Hour).Add(time.Duration(minute) * time.Minute)
+			if scheduled.After(now) {
+				scheduled = scheduled.Add(-time.Hour)
+			}
+			if scheduled.After(latest) {
+				latest = scheduled
+			}
+		}
+		if latest.IsZero() {
+			continue
+		}
+
+		last, seen := a.lastDue[brk.Name]
+		a.lastDue[brk.Name] = latest
+		// The first check after startup only records the schedule; missed breaks are not replayed
+		if seen && latest.After(last) {
+			due = append(due, brk.Name)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+// StartBreakScheduler triggers breaks at their scheduled minutes past the hour
+func StartBreakScheduler() {
+	if len(Config.Breaks) == 0 {
+		return
+	}
+
+	go func() {
+		AdBreaks.dueBreaks(time.Now())
+
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			for _, name := range AdBreaks.dueBreaks(now) {
+				if _, err := AdBreaks.TriggerBreak(name); err != nil {
+					Logger.Error(err)
+				}
+			}
+		}
+	}()
+
+	Logger.Info(fmt.Sprintf("Break scheduler started (%d breaks, %d spots)", len(Config.Breaks), len(Config.Spots)))
+}
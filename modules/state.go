@@ -0,0 +1,247 @@
+package modules
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Files in the data directory holding the playout state
+const (
+	stateFile       = "state.json"
+	recentPlaysFile = "recent_plays.json"
+)
+
+// How often the reader saves the playout state
+const stateSaveInterval = 5 * time.Second
+
+// PlayoutState is what the reader needs to resume where it left off after a restart
+type PlayoutState struct {
+	CurrentSongHash string            `json:"current_song_hash"`
+	CachedNextHash  string            `json:"cached_next_hash"`
+	Playlist        []string          `json:"playlist"`
+	Playing         bool              `json:"playing"`  // The current song was on air (not a jingle or live source)
+	File            string            `json:"file"`     // File the current song was read from (may be the transcoded copy)
+	Position        int64             `json:"position"` // Byte offset in File
+	AutomationHash  string            `json:"automation_hash"`
+	AutomationKey   string            `json:"automation_key"`
+	ScheduleLast    map[string]string `json:"schedule_last"` // Last hash per schedule slot
+	PlaylistLast    map[string]string `json:"playlist_last"` // Last hash per named playlist in rotation
+	Clock           ClockWheelState   `json:"clock"`
+	Saved           int64             `json:"saved"` // Unix milliseconds
+}
+
+// ClockWheelState is the position within the clock wheel
+type ClockWheelState struct {
+	Clock    string            `json:"clock"`
+	Hour     int64             `json:"hour"` // Unix milliseconds of the hour start
+	Picks    int               `json:"picks"`
+	Position int               `json:"position"`
+	Last     map[string]string `json:"last"` // Last hash per category
+}
+
+var lastStateSave time.Time
+
+// saveStateIfDue saves the playout state every stateSaveInterval.
+// Called from the reader loop, which owns the open file.
+func (musicReader *IMusicReader) saveStateIfDue() {
+	now := time.Now()
+	if now.Sub(lastStateSave) < stateSaveInterval {
+		return
+	}
+	lastStateSave = now
+	musicReader.saveState(now)
+}
+
+func (musicReader *IMusicReader) saveState(now time.Time) {
+	musicReader.Lock.RLock()
+	state := PlayoutState{
+		CurrentSongHash: musicReader.CurrentSongHash,
+		CachedNextHash:  musicReader.CachedNextHash,
+		Playlist:        make([]string, len(musicReader.Playlist)),
+		AutomationHash:  musicReader.automationHash,
+		AutomationKey:   musicReader.automationKey,
+		Saved:           now.UnixMilli(),
+	}
+	copy(state.Playlist, musicReader.Playlist)
+	icecastMode := musicReader.IsIcecastMode
+	musicReader.Lock.RUnlock()
+
+	// Spots are queued per break and don't survive a restart
+	state.Playlist = withoutSpots(state.Playlist)
+	if AdBreaks.IsSpot(state.CachedNextHash) {
+		state.CachedNextHash = ""
+	}
+
+	current := CurrentOnAir()
+	if !icecastMode && musicReader.File != nil && current.Type == OnAirTrack && current.Hash == state.CurrentSongHash {
+		if offset, err := musicReader.File.Seek(0, io.SeekCurrent); err == nil {
+			state.Playing = true
+			state.File = musicReader.File.Name()
+			// Listeners are behind the reader by the buffered audio
+			if store := musicReader.GetBufferStoreData(); store != nil {
+				offset -= int64(len(store.InitialBuffer))
+			}
+			if offset < 0 {
+				offset = 0
+			}
+			state.Position = offset
+		}
+	}
+
+	scheduleState.mu.Lock()
+	state.ScheduleLast = make(map[string]string, len(scheduleState.last))
+	for slot, hash := range scheduleState.last {
+		state.ScheduleLast[slot] = hash
+	}
+	scheduleState.mu.Unlock()
+
+	Playlists.mu.Lock()
+	state.PlaylistLast = make(map[string]string, len(Playlists.last))
+	for name, hash := range Playlists.last {
+		state.PlaylistLast[name] = hash
+	}
+	Playlists.mu.Unlock()
+	state.Clock = ClockWheel.snapshot()
+
+	if err := SaveJSON(stateFile, state); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save playout state: %v", err))
+	}
+}
+
+func withoutSpots(hashes []string) []string {
+	kept := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		if !AdBreaks.IsSpot(hash) {
+			kept = append(kept, hash)
+		}
+	}
+	return kept
+}
+
+// restoreState resumes the queue, the current song and the rotation positions
+// saved by a previous run. Must run before the reader loop starts.
+func (musicReader *IMusicReader) restoreState() {
+	loadRecentPlays()
+
+	var state PlayoutState
+	if err := LoadJSON(stateFile, &state); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load playout state: %v", err))
+		return
+	}
+	if state.Saved == 0 {
+		return
+	}
+
+	// Register the song hashes of the library
+	if _, err := GetMp3FilePaths(); err != nil {
+		Logger.Error(err)
+		return
+	}
+	exists := func(hash string) bool {
+		_, ok := FindSongByHash(hash)
+		return hash != "" && ok
+	}
+
+	// Songs saved under an earlier hash continue under their current one
+	for i, hash := range state.Playlist {
+		state.Playlist[i] = Library.ResolveHash(hash)
+	}
+	state.CachedNextHash = Library.ResolveHash(state.CachedNextHash)
+	state.CurrentSongHash = Library.ResolveHash(state.CurrentSongHash)
+
+	musicReader.Lock.Lock()
+	musicReader.Playlist = []string{}
+	for _, hash := range state.Playlist {
+		if exists(hash) {
+			musicReader.Playlist = append(musicReader.Playlist, hash)
+		}
+	}
+	if exists(state.CachedNextHash) {
+		musicReader.CachedNextHash = state.CachedNextHash
+	}
+	musicReader.automationHash = state.AutomationHash
+	musicReader.automationKey = state.AutomationKey
+	musicReader.Lock.Unlock()
+
+	scheduleState.mu.Lock()
+	for slot, hash := range state.ScheduleLast {
+		scheduleState.last[slot] = hash
+	}
+	scheduleState.mu.Unlock()
+
+	Playlists.mu.Lock()
+	for name, hash := range state.PlaylistLast {
+		Playlists.last[name] = hash
+	}
+	Playlists.mu.Unlock()
+	ClockWheel.restore(state.Clock)
+
+	if !exists(state.CurrentSongHash) {
+		Logger.Info(fmt.Sprintf("Restored playout state: %d songs queued", len(musicReader.Playlist)))
+		return
+	}
+	// Sequential playback continues after the last song even when it isn't resumed
+	musicReader.CurrentSongHash = state.CurrentSongHash
+	if state.Playing {
+		musicReader.resumeSong(state)
+	}
+	Logger.Info(fmt.Sprintf("Restored playout state: %d songs queued, resuming at byte %d", len(musicReader.Playlist), state.Position))
+}
+
+// resumeSong reopens the song that was on air and seeks to the saved position
+func (musicReader *IMusicReader) resumeSong(state PlayoutState) {
+	filePath := state.File
+	position := state.Position
+	if _, err := os.Stat(filePath); err != nil {
+		// The transcoded copy is gone; start the song over
+		filePath, _ = FindSongByHash(state.CurrentSongHash)
+		if transcodedPath, err := TranscodeAudio(filePath); err == nil {
+			filePath = transcodedPath
+		}
+		position = 0
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	musicReader.File = file
+	musicReader.ResetMusicInfo(filePath)
+	if musicReader.File == nil {
+		return
+	}
+
+	if info, err := musicReader.File.Stat(); err == nil && position > 0 && position < info.Size() {
+		// The reader resynchronises on the next frame header
+		if _, err := musicReader.File.Seek(position, io.SeekStart); err != nil {
+			Logger.Error(err)
+		}
+	}
+
+	Jingles.songStarted()
+	if info := musicReader.GetMusicInfoStoreData(); info != nil {
+		AnnounceOnAir(OnAirItem{
+			Type:     OnAirTrack,
+			Hash:     state.CurrentSongHash,
+			Title:    info.Title,
+			Artist:   info.Artist,
+			Filename: info.Filename,
+		})
+	}
+}
+
+// loadRecentPlays restores the separation history saved by recordRecentPlay
+func loadRecentPlays() {
+	var plays []recentPlay
+	if err := LoadJSON(recentPlaysFile, &plays); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load recent plays: %v", err))
+		return
+	}
+
+	recentPlays.mu.Lock()
+	defer recentPlays.mu.Unlock()
+	recentPlays.plays = plays
+}
@@ -0,0 +1,41 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SaveJSON writes v as JSON to name inside the data directory.
+// The file is replaced atomically so a crash never leaves a partial file behind.
+func SaveJSON(name string, v interface{}) error {
+	if err := os.MkdirAll(Config.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(Config.DataDir, name)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadJSON reads name from the data directory into v
+// A missing file is not an error and leaves v untouched
+func LoadJSON(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(Config.DataDir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
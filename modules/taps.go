@@ -0,0 +1,43 @@
+package modules
+
+import (
+	"sync"
+)
+
+// Output taps receive every block of audio that goes on air, in broadcast order.
+// Recorders use them to capture the outgoing station stream.
+var outputTaps = struct {
+	mu   sync.RWMutex
+	taps map[string]func([]byte)
+}{
+	taps: make(map[string]func([]byte)),
+}
+
+// AddOutputTap registers a function that receives the outgoing stream
+// Taps are called synchronously from the reader and must not block
+func AddOutputTap(name string, tap func([]byte)) {
+	outputTaps.mu.Lock()
+	defer outputTaps.mu.Unlock()
+	outputTaps.taps[name] = tap
+}
+
+// RemoveOutputTap unregisters an output tap
+func RemoveOutputTap(name string) {
+	outputTaps.mu.Lock()
+	defer outputTaps.mu.Unlock()
+	delete(outputTaps.taps, name)
+}
+
+// writeOutputTaps hands a block of outgoing audio to all registered taps
+func writeOutputTaps(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	outputTaps.mu.RLock()
+	defer outputTaps.mu.RUnlock()
+
+	for _, tap := range outputTaps.taps {
+		tap(data)
+	}
+}
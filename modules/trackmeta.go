@@ -0,0 +1,95 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// TrackMeta holds the ID3 tags used to select and separate songs
+type TrackMeta struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Genre  string `json:"genre"`
+	Year   int    `json:"year"`
+}
+
+type trackMetaEntry struct {
+	meta    TrackMeta
+	modTime time.Time
+}
+
+// Cache of parsed tags by file path; entries are refreshed when the file changes
+var trackMetaCache = &sync.Map{}
+
+// GetTrackMeta returns the tags of a song by hash from the library index,
+// reading songs outside the library only when they changed
+func GetTrackMeta(hash string) (TrackMeta, bool) {
+	if entry, ok := Library.Entry(hash); ok {
+		meta := entry.TrackMeta
+		if meta.Title == "" {
+			meta.Title = strings.TrimSuffix(filepath.Base(entry.Path), filepath.Ext(entry.Path))
+		}
+		return meta, true
+	}
+	path, ok := FindSongByHash(hash)
+	if !ok {
+		return TrackMeta{}, false
+	}
+	return readTrackMeta(path)
+}
+
+func readTrackMeta(path string) (TrackMeta, bool) {
+	meta, ok := cachedID3Tags(path)
+	if !ok {
+		return meta, false
+	}
+	if meta.Title == "" {
+		meta.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return meta, true
+}
+
+// cachedID3Tags returns the tags of a file, parsing it again only when it changed
+func cachedID3Tags(path string) (TrackMeta, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return TrackMeta{}, false
+	}
+
+	if cached, ok := trackMetaCache.Load(path); ok {
+		entry := cached.(trackMetaEntry)
+		if entry.modTime.Equal(info.ModTime()) {
+			return entry.meta, true
+		}
+	}
+
+	meta := readID3Tags(path)
+	trackMetaCache.Store(path, trackMetaEntry{meta: meta, modTime: info.ModTime()})
+	return meta, true
+}
+
+// readID3Tags parses the ID3 tags of a file; missing tags are left empty
+func readID3Tags(path string) TrackMeta {
+	meta := TrackMeta{}
+	tag, err := id3v2.Open(path, id3v2.Options{Parse: true})
+	if err != nil {
+		return meta
+	}
+	defer tag.Close()
+
+	meta.Title = tag.Title()
+	meta.Artist = tag.Artist()
+	meta.Album = tag.Album()
+	meta.Genre = tag.Genre()
+	if len(tag.Year()) >= 4 {
+		meta.Year, _ = strconv.Atoi(tag.Year()[:4])
+	}
+	return meta
+}
@@ -0,0 +1,311 @@
+package modules
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// File in the data directory describing the files of the transcode cache
+const transcodeManifestFile = "transcode_cache.json"
+
+// TranscodeCacheEntry describes a transcoded file and the source it was made from
+type TranscodeCacheEntry struct {
+	Hash        string `json:"hash"` // Track hash of the source
+	Source      string `json:"source"`
+	SourceMTime int64  `json:"source_mtime"` // Unix nanoseconds
+	SourceSize  int64  `json:"source_size"`
+	Params      string `json:"params"` // ffmpeg output options
+	Size        int64  `json:"size"`
+	Created     int64  `json:"created"`   // Unix milliseconds
+	LastUsed    int64  `json:"last_used"` // Unix milliseconds the file was last played, 0 = never
+}
+
+// TranscodeCacheStats counts cache lookups and evictions since startup.
+// Hits and misses count plays; songs transcoded ahead of time count as pretranscodes.
+type TranscodeCacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Pretranscodes int64 `json:"pretranscodes"`
+	Evictions     int64 `json:"evictions"`
+	Files         int   `json:"files"`
+	SizeBytes     int64 `json:"size_bytes"`
+	MaxBytes      int64 `json:"max_bytes"` // 0 = unlimited
+}
+
+// ITranscodeCache keeps the manifest of the transcode cache, by file name.
+// A cached file is used only while its source keeps the modification time and
+// size it had when it was transcoded. Above cache_max_mb the least recently
+// played files are evicted.
+type ITranscodeCache struct {
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]*TranscodeCacheEntry
+
+	hits          int64
+	misses        int64
+	pretranscodes int64
+	evictions     int64
+}
+
+var TranscodeCache = &ITranscodeCache{
+	entries: make(map[string]*TranscodeCacheEntry),
+}
+
+func (c *ITranscodeCache) loadLocked() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+
+	if err := LoadJSON(transcodeManifestFile, &c.entries); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load transcode cache manifest: %v", err))
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*TranscodeCacheEntry)
+	}
+	// Files removed while the server was stopped
+	for name, entry := range c.entries {
+		info, err := os.Stat(filepath.Join(Config.CacheDir, name))
+		if err != nil {
+			delete(c.entries, name)
+			continue
+		}
+		entry.Size = info.Size()
+	}
+}
+
+func (c *ITranscodeCache) saveLocked() {
+	if err := SaveJSON(transcodeManifestFile, c.entries); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save transcode cache manifest: %v", err))
+	}
+}
+
+// transcodeParams returns the ffmpeg output options of the stream format.
+// Changing any of them (bitrate, sample rate, filters) gives cached files a new name.
+func transcodeParams() []string {
+	return []string{
+		"-b:a", Config.StandardBitrate,
+		"-ar", Config.StandardSampleRate,
+	}
+}
+
+// transcodeFileName names the cached file of a song: its track hash and a digest of the transcode parameters
+func transcodeFileName(originalPath string) string {
+	digest := md5.Sum([]byte(strings.Join(transcodeParams(), " ")))
+	return fmt.Sprintf("%s-%s.mp3", Library.HashForPath(originalPath), hex.EncodeToString(digest[:4]))
+}
+
+// valid reports whether a cached file exists and was made from the current version of its source
+func (c *ITranscodeCache) valid(name, originalPath string) bool {
+	info, err := os.Stat(originalPath)
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(Config.CacheDir, name)); err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	entry, ok := c.entries[name]
+	return ok && entry.SourceMTime == info.ModTime().UnixNano() && entry.SourceSize == info.Size()
+}
+
+// record adds a freshly transcoded file to the manifest; played marks it as used now
+func (c *ITranscodeCache) record(name, originalPath string, source os.FileInfo, played bool) {
+	hash := Library.HashForPath(originalPath)
+	size := int64(0)
+	if info, err := os.Stat(filepath.Join(Config.CacheDir, name)); err == nil {
+		size = info.Size()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	now := time.Now().UnixMilli()
+	entry := &TranscodeCacheEntry{
+		Hash:        hash,
+		Source:      originalPath,
+		SourceMTime: source.ModTime().UnixNano(),
+		SourceSize:  source.Size(),
+		Params:      strings.Join(transcodeParams(), " "),
+		Size:        size,
+		Created:     now,
+	}
+	if played {
+		entry.LastUsed = now
+	}
+	c.entries[name] = entry
+	c.saveLocked()
+}
+
+// miss counts a song that had to be transcoded to be played
+func (c *ITranscodeCache) miss() {
+	atomic.AddInt64(&c.misses, 1)
+}
+
+// pretranscoded counts a song transcoded ahead of its play
+func (c *ITranscodeCache) pretranscoded() {
+	atomic.AddInt64(&c.pretranscodes, 1)
+}
+
+// use marks a cached file as played. Its modification time is renewed too,
+// so cache_ttl_minutes counts from the last play.
+func (c *ITranscodeCache) use(name string) {
+	atomic.AddInt64(&c.hits, 1)
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(Config.CacheDir, name), now, now); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to touch cache file %s: %v", name, err))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	if entry, ok := c.entries[name]; ok {
+		entry.LastUsed = now.UnixMilli()
+		c.saveLocked()
+	}
+}
+
+// Files in the cache directory that are not in the manifest (artwork, files of
+// older versions, partial transcodes) are evicted only once they are this old
+const untrackedCacheGrace = 10 * time.Minute
+
+// cacheCandidate is a file that can be evicted from the cache directory
+type cacheCandidate struct {
+	path    string
+	name    string // Manifest entry, "" for untracked files
+	size    int64
+	lastUse int64 // Unix milliseconds
+}
+
+// enforceLimit evicts files until the cache directory fits in cache_max_mb: the
+// least recently played transcoded files (never played ones by age) and untracked
+// files by modification time, oldest first. Files in protected (full paths) are kept.
+func (c *ITranscodeCache) enforceLimit(protected map[string]bool) {
+	if Config.CacheMaxMB <= 0 {
+		return
+	}
+	limit := int64(Config.CacheMaxMB) * 1024 * 1024
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	// Everything in the directory counts towards the limit, not only the manifest
+	now := time.Now()
+	total := int64(0)
+	candidates := []cacheCandidate{}
+	err := filepath.Walk(Config.CacheDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		total += info.Size()
+		name, _ := filepath.Rel(Config.CacheDir, path)
+		if entry, ok := c.entries[name]; ok {
+			entry.Size = info.Size()
+			lastUse := entry.LastUsed
+			if lastUse == 0 {
+				lastUse = entry.Created
+			}
+			candidates = append(candidates, cacheCandidate{path: path, name: name, size: info.Size(), lastUse: lastUse})
+		} else if now.Sub(info.ModTime()) > untrackedCacheGrace {
+			candidates = append(candidates, cacheCandidate{path: path, size: info.Size(), lastUse: info.ModTime().UnixMilli()})
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		Logger.Error(fmt.Sprintf("Failed to measure cache directory: %v", err))
+		return
+	}
+	if total <= limit {
+		return
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := c.entries[candidates[i].name], c.entries[candidates[j].name]
+		// Files played at some point are kept over files never played
+		if (a != nil && a.LastUsed > 0) != (b != nil && b.LastUsed > 0) {
+			return b != nil && b.LastUsed > 0
+		}
+		return candidates[i].lastUse < candidates[j].lastUse
+	})
+
+	evicted, freed := 0, int64(0)
+	for _, candidate := range candidates {
+		if total <= limit {
+			break
+		}
+		if protected[candidate.path] {
+			continue
+		}
+		if err := os.Remove(candidate.path); err != nil && !os.IsNotExist(err) {
+			Logger.Error(fmt.Sprintf("Failed to evict cache file %s: %v", filepath.Base(candidate.path), err))
+			continue
+		}
+		if candidate.name != "" {
+			delete(c.entries, candidate.name)
+		}
+		total -= candidate.size
+		freed += candidate.size
+		evicted++
+	}
+	if evicted == 0 {
+		return
+	}
+	atomic.AddInt64(&c.evictions, int64(evicted))
+	c.saveLocked()
+	Logger.Info(fmt.Sprintf("Cache limit: evicted %d files, freed %.2f MB, %.2f of %d MB used", evicted, float64(freed)/(1024*1024), float64(total)/(1024*1024), Config.CacheMaxMB))
+}
+
+// Stats returns the cache counters and the size of the transcoded files
+func (c *ITranscodeCache) Stats() TranscodeCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	stats := TranscodeCacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Pretranscodes: atomic.LoadInt64(&c.pretranscodes),
+		Evictions:     atomic.LoadInt64(&c.evictions),
+		Files:         len(c.entries),
+		MaxBytes:      int64(Config.CacheMaxMB) * 1024 * 1024,
+	}
+	for _, entry := range c.entries {
+		stats.SizeBytes += entry.Size
+	}
+	return stats
+}
+
+// forget drops the manifest entries of cached files that were deleted
+func (c *ITranscodeCache) forget(names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+
+	changed := false
+	for _, name := range names {
+		if _, ok := c.entries[name]; ok {
+			delete(c.entries, name)
+			changed = true
+		}
+	}
+	if changed {
+		c.saveLocked()
+	}
+}
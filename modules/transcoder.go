@@ -39,29 +39,42 @@ func GetFFmpegPath() (string, error) {
 	return "", fmt.Errorf("ffmpeg not found in PATH or bundled directory")
 }
 
-// GetCachedPath returns the path where a normalized file should be cached
+// GetCachedPath returns the path where a normalized file should be cached.
+// The name is made of the track hash and the transcode parameters, so songs with
+// the same file name don't collide and changed settings don't reuse old files.
 func GetCachedPath(originalPath string) string {
-	filename := filepath.Base(originalPath)
-	return filepath.Join(Config.CacheDir, filename)
+	return filepath.Join(Config.CacheDir, transcodeFileName(originalPath))
 }
 
-// IsCached checks if a normalized version exists in cache
+// IsCached checks if a normalized version of the current source exists in cache
 func IsCached(originalPath string) bool {
-	cachedPath := GetCachedPath(originalPath)
-	_, err := os.Stat(cachedPath)
-	return err == nil
+	return TranscodeCache.valid(filepath.Base(GetCachedPath(originalPath)), originalPath)
 }
 
-// TranscodeAudio transcodes an MP3 file to standard format
+// TranscodeAudio transcodes an MP3 file to standard format for playback
 // Returns the path to the normalized file (either cached or original)
 // Note: Normalization is always enabled for consistent stream quality
 func TranscodeAudio(filePath string) (string, error) {
+	return transcodeAudio(filePath, true)
+}
+
+// transcodeAudio transcodes a file into the cache. Only plays count as cache hits
+// and misses and renew the last use of the cached file.
+func transcodeAudio(filePath string, play bool) (string, error) {
 	// Check if already cached
 	cachedPath := GetCachedPath(filePath)
 	if IsCached(filePath) {
 		Logger.Info(fmt.Sprintf("Using cached version: %s", cachedPath))
+		if play {
+			TranscodeCache.use(filepath.Base(cachedPath))
+		}
 		return cachedPath, nil
 	}
+	if play {
+		TranscodeCache.miss()
+	} else {
+		TranscodeCache.pretranscoded()
+	}
 
 	// Ensure cache directory exists
 	if err := os.MkdirAll(Config.CacheDir, 0755); err != nil {
@@ -76,24 +89,40 @@ func TranscodeAudio(filePath string) (string, error) {
 		return filePath, nil // Fallback to original
 	}
 
-	// Run ffmpeg transcoding
+	// The source is described as it was before transcoding; a later edit invalidates the file
+	source, err := os.Stat(filePath)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Transcoding failed: %v", err))
+		return filePath, nil // Fallback to original
+	}
+
+	// Run ffmpeg transcoding into a partial file, so a stale or incomplete file is never played
 	Logger.Info(fmt.Sprintf("Transcoding: %s", filepath.Base(filePath)))
-	cmd := exec.Command(ffmpegPath,
-		"-i", filePath,
-		"-b:a", Config.StandardBitrate,
-		"-ar", Config.StandardSampleRate,
+	partialPath := fmt.Sprintf("%s.%d.part", cachedPath, time.Now().UnixNano())
+	args := append([]string{"-i", filePath}, transcodeParams()...)
+	args = append(args,
+		"-f", "mp3",
 		"-y", // Overwrite if exists
-		cachedPath,
+		partialPath,
 	)
+	cmd := exec.Command(ffmpegPath, args...)
 
 	// Suppress output
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 
 	if err := cmd.Run(); err != nil {
+		os.Remove(partialPath)
 		Logger.Error(fmt.Sprintf("Transcoding failed: %v", err))
 		return filePath, nil // Fallback to original
 	}
+	if err := os.Rename(partialPath, cachedPath); err != nil {
+		os.Remove(partialPath)
+		Logger.Error(fmt.Sprintf("Transcoding failed: %v", err))
+		return filePath, nil // Fallback to original
+	}
+	TranscodeCache.record(filepath.Base(cachedPath), filePath, source, play)
+	go EnforceCacheLimit()
 
 	Logger.Info(fmt.Sprintf("Transcoded successfully: %s", filepath.Base(cachedPath)))
 	return cachedPath, nil
@@ -128,7 +157,7 @@ func PreTranscodeAudioAsync(filePath string) {
 	}
 	
 	// Transcode in background
-	_, err := TranscodeAudio(filePath)
+	_, err := transcodeAudio(filePath, false)
 	if err != nil {
 		// Silently fail - not critical if pre-transcoding fails
 		return
@@ -137,22 +166,16 @@ func PreTranscodeAudioAsync(filePath string) {
 	Logger.Info(fmt.Sprintf("Pre-transcoded (cache): %s", filepath.Base(filePath)))
 }
 
-// CleanOldCacheFiles removes cache files older than the configured TTL
-// It preserves: the next song cache file (CachedNextHash) and all songs in the Playlist
-func CleanOldCacheFiles() error {
-	if Config.CacheTTLMinutes <= 0 {
-		// Cleanup disabled
-		return nil
-	}
+// protectedCacheFiles returns the cache files that must not be deleted:
+// the song on air, the next song (CachedNextHash) and all songs in the Playlist
+func protectedCacheFiles() map[string]bool {
+	protectedFiles := make(map[string]bool)
 
-	cacheDir := Config.CacheDir
-	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-		return nil // Cache directory doesn't exist
+	// Protect the song on air
+	if currentFilePath, exists := FindSongByHash(MusicReader.CurrentSongHash); exists {
+		protectedFiles[GetCachedPath(currentFilePath)] = true
 	}
 
-	// Build a set of protected cache file paths (files not to delete)
-	protectedFiles := make(map[string]bool)
-
 	// Protect the next song cache file
 	if MusicReader.CachedNextHash != "" {
 		if nextFilePath, exists := FindSongByHash(MusicReader.CachedNextHash); exists {
@@ -171,12 +194,37 @@ func CleanOldCacheFiles() error {
 			Logger.Debug(fmt.Sprintf("Protecting playlist song cache: %s", filepath.Base(protectedPath)))
 		}
 	}
+	return protectedFiles
+}
+
+// EnforceCacheLimit evicts the least recently played transcoded files above cache_max_mb,
+// keeping the song on air, the next song and the queued songs
+func EnforceCacheLimit() {
+	TranscodeCache.enforceLimit(protectedCacheFiles())
+}
+
+// CleanOldCacheFiles removes cache files older than the configured TTL
+// It preserves: the song on air, the next song cache file (CachedNextHash) and all songs in the Playlist
+func CleanOldCacheFiles() error {
+	if Config.CacheTTLMinutes <= 0 {
+		// Cleanup disabled
+		return nil
+	}
+
+	cacheDir := Config.CacheDir
+	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
+		return nil // Cache directory doesn't exist
+	}
+
+	// Build a set of protected cache file paths (files not to delete)
+	protectedFiles := protectedCacheFiles()
 
 	ttlDuration := time.Duration(Config.CacheTTLMinutes) * time.Minute
 	now := time.Now()
 	deletedCount := 0
 	skippedCount := 0
 	totalSize := int64(0)
+	deletedNames := []string{}
 
 	err := filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -204,12 +252,15 @@ func CleanOldCacheFiles() error {
 			}
 			deletedCount++
 			totalSize += info.Size()
+			deletedNames = append(deletedNames, filepath.Base(path))
 			Logger.Info(fmt.Sprintf("Deleted old cache file: %s (age: %v)", filepath.Base(path), fileAge))
 		}
 
 		return nil
 	})
 
+	TranscodeCache.forget(deletedNames)
+
 	if deletedCount > 0 || skippedCount > 0 {
 		freedMB := float64(totalSize) / (1024 * 1024)
 		Logger.Info(fmt.Sprintf("Cache cleanup: Deleted %d files, freed %.2f MB, protected %d files (next song + playlist)", deletedCount, freedMB, skippedCount))
@@ -220,6 +271,11 @@ func CleanOldCacheFiles() error {
 
 // StartCacheCleanupRoutine starts a background routine to periodically clean old cache files
 func StartCacheCleanupRoutine() {
+	// The size limit is checked whenever a file is added; this applies a lowered limit right away
+	if Config.CacheMaxMB > 0 {
+		go EnforceCacheLimit()
+	}
+
 	if Config.CacheTTLMinutes <= 0 {
 		Logger.Info("Cache cleanup disabled (cache_ttl_minutes = 0)")
 		return
@@ -0,0 +1,304 @@
+package modules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// File in the data directory holding track ratings
+const ratingsFile = "ratings.json"
+
+// File in the data directory holding each listener's rating per song
+const ratingVotesFile = "rating_votes.json"
+
+// TrackRating holds the like and dislike counts of a song
+type TrackRating struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+// SkipVoteStatus describes the skip vote on the current song
+type SkipVoteStatus struct {
+	Hash      string `json:"hash"`
+	Votes     int    `json:"votes"`
+	Needed    int    `json:"needed"`
+	Listeners int64  `json:"listeners"`
+	Skipped   bool   `json:"skipped"`
+}
+
+// ISkipVotes counts listener votes to skip the song on air
+type ISkipVotes struct {
+	mu     sync.Mutex
+	hash   string
+	voters map[string]bool
+}
+
+var SkipVotes = &ISkipVotes{
+	voters: make(map[string]bool),
+}
+
+// ratingVoteKey identifies the rating of a song by a listener
+type ratingVoteKey struct {
+	voter string
+	hash  string
+}
+
+// savedRatingVote is a listener's rating of a song as stored in ratingVotesFile
+type savedRatingVote struct {
+	Voter string `json:"voter"`
+	Hash  string `json:"hash"`
+	Like  bool   `json:"like"`
+}
+
+// ITrackRatings keeps persistent like and dislike counts per song
+type ITrackRatings struct {
+	mu      sync.Mutex
+	loaded  bool
+	ratings map[string]*TrackRating
+	votes   map[ratingVoteKey]bool // Whether each listener likes a song
+}
+
+var TrackRatings = &ITrackRatings{
+	ratings: make(map[string]*TrackRating),
+	votes:   make(map[ratingVoteKey]bool),
+}
+
+// ratingVoter identifies a listener without keeping their address on disk
+func ratingVoter(voter string) string {
+	sum := sha256.Sum256([]byte(voter))
+	return hex.EncodeToString(sum[:16])
+}
+
+func init() {
+	AddOnAirListener(SkipVotes.onAir)
+}
+
+// onAir starts a new vote for every item that goes on air
+func (s *ISkipVotes) onAir(item OnAirItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hash = item.Hash
+	s.voters = make(map[string]bool)
+}
+
+// votesNeeded returns how many votes skip the current song with the current audience
+func votesNeeded(listeners int64) int {
+	needed := int(math.Ceil(float64(listeners) * Config.SkipVoteShare))
+	if needed < Config.SkipVoteMin {
+		needed = Config.SkipVoteMin
+	}
+	if needed < 1 {
+		needed = 1
+	}
+	return needed
+}
+
+// Vote records a listener's vote to skip the current song and skips it once enough listeners agree
+func (s *ISkipVotes) Vote(voter string) (SkipVoteStatus, error) {
+	if !Config.SkipVoteEnabled {
+		return SkipVoteStatus{}, fmt.Errorf("skip voting is disabled")
+	}
+
+	current := CurrentOnAir()
+	if current.Type != OnAirTrack || current.Hash == "" {
+		return SkipVoteStatus{}, fmt.Errorf("only songs can be skipped")
+	}
+
+	s.mu.Lock()
+	if s.hash != current.Hash {
+		s.hash = current.Hash
+		s.voters = make(map[string]bool)
+	}
+	if s.voters[voter] {
+		s.mu.Unlock()
+		return SkipVoteStatus{}, fmt.Errorf("you already voted to skip this song")
+	}
+	s.voters[voter] = true
+
+	listeners := ActiveListenerCount()
+	status := SkipVoteStatus{
+		Hash:      s.hash,
+		Votes:     len(s.voters),
+		Needed:    votesNeeded(listeners),
+		Listeners: listeners,
+	}
+	status.Skipped = status.Votes >= status.Needed
+	if status.Skipped {
+		// Votes don't carry over to the next song
+		s.voters = make(map[string]bool)
+	}
+	s.mu.Unlock()
+
+	if status.Skipped {
+		Logger.Info(fmt.Sprintf("Skip vote passed (%d of %d listeners)", status.Votes, listeners))
+		MusicReader.SkipToNext()
+	}
+	return status, nil
+}
+
+// Status returns the skip vote on the current song
+func (s *ISkipVotes) Status() SkipVoteStatus {
+	current := CurrentOnAir()
+	listeners := ActiveListenerCount()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	votes := 0
+	if s.hash == current.Hash {
+		votes = len(s.voters)
+	}
+	return SkipVoteStatus{
+		Hash:      current.Hash,
+		Votes:     votes,
+		Needed:    votesNeeded(listeners),
+		Listeners: listeners,
+	}
+}
+
+func (r *ITrackRatings) loadLocked() {
+	if r.loaded {
+		return
+	}
+	r.loaded = true
+
+	if err := LoadJSON(ratingsFile, &r.ratings); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load track ratings: %v", err))
+	}
+	if r.ratings == nil {
+		r.ratings = make(map[string]*TrackRating)
+	}
+
+	var votes []savedRatingVote
+	if err := LoadJSON(ratingVotesFile, &votes); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to load rating votes: %v", err))
+	}
+	for _, vote := range votes {
+		r.votes[ratingVoteKey{voter: vote.Voter, hash: vote.Hash}] = vote.Like
+	}
+}
+
+// saveLocked stores the ratings and the votes behind them
+func (r *ITrackRatings) saveLocked() {
+	if err := SaveJSON(ratingsFile, r.ratings); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save track ratings: %v", err))
+	}
+
+	votes := make([]savedRatingVote, 0, len(r.votes))
+	for key, like := range r.votes {
+		votes = append(votes, savedRatingVote{Voter: key.voter, Hash: key.hash, Like: like})
+	}
+	if err := SaveJSON(ratingVotesFile, votes); err != nil {
+		Logger.Error(fmt.Sprintf("Failed to save rating votes: %v", err))
+	}
+}
+
+// Rate records a like or dislike from a listener. A listener rates each song once;
+// rating it again the other way replaces their previous vote.
+func (r *ITrackRatings) Rate(hash, voter string, like bool) (TrackRating, error) {
+	if _, ok := FindSongByHash(hash); !ok {
+		return TrackRating{}, fmt.Errorf("song hash not found")
+	}
+	hash = Library.ResolveHash(hash)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+
+	rating, ok := r.ratings[hash]
+	if !ok {
+		rating = &TrackRating{}
+		r.ratings[hash] = rating
+	}
+
+	key := ratingVoteKey{voter: ratingVoter(voter), hash: hash}
+	if previous, voted := r.votes[key]; voted {
+		if previous == like {
+			return *rating, fmt.Errorf("you already rated this song")
+		}
+		if previous {
+			rating.Likes--
+		} else {
+			rating.Dislikes--
+		}
+	}
+	r.votes[key] = like
+	if like {
+		rating.Likes++
+	} else {
+		rating.Dislikes++
+	}
+	invalidateSmartPlaylists()
+
+	r.saveLocked()
+	return *rating, nil
+}
+
+// Get returns the rating of a song
+func (r *ITrackRatings) Get(hash string) TrackRating {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+
+	if rating, ok := r.ratings[hash]; ok {
+		return *rating
+	}
+	return TrackRating{}
+}
+
+// renameSong moves the rating of a song and the votes on it to its new hash
+func (r *ITrackRatings) renameSong(oldHash, newHash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+
+	rating, ok := r.ratings[oldHash]
+	moved := false
+	for key, like := range r.votes {
+		if key.hash != oldHash {
+			continue
+		}
+		delete(r.votes, key)
+		moved = true
+		newKey := ratingVoteKey{voter: key.voter, hash: newHash}
+		if _, voted := r.votes[newKey]; !voted {
+			r.votes[newKey] = like
+			continue
+		}
+		// The listener already rated the new hash; keep only that vote
+		if ok && like {
+			rating.Likes--
+		} else if ok {
+			rating.Dislikes--
+		}
+	}
+
+	if !ok {
+		if moved {
+			r.saveLocked()
+		}
+		return
+	}
+	delete(r.ratings, oldHash)
+	if existing, ok := r.ratings[newHash]; ok {
+		existing.Likes += rating.Likes
+		existing.Dislikes += rating.Dislikes
+	} else {
+		r.ratings[newHash] = rating
+	}
+
+	r.saveLocked()
+}
+
+// ratingWeight scales a song's rotation weight by its ratings: a song with more
+// dislikes than likes comes up less often, down to a tenth of its normal weight
+func ratingWeight(hash string) float64 {
+	rating := TrackRatings.Get(hash)
+	if rating.Dislikes <= rating.Likes {
+		return 1
+	}
+	return math.Max(0.1, float64(rating.Likes+1)/float64(rating.Dislikes+1))
+}
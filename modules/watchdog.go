@@ -0,0 +1,384 @@
+package modules
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Silence floor reported when the decoded signal is digital zero
+const levelMeterFloorDb = -120.0
+
+// LevelMeter decodes live chunks with FFmpeg into low-rate mono PCM and tracks the RMS level
+// Decoding runs in its own process so a slow meter never blocks the live feeder
+type LevelMeter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	input  chan []byte
+
+	mu            sync.RWMutex
+	isRunning     bool
+	exited        bool // The decoder stopped producing output (FFmpeg exited)
+	levelDb       float64
+	lastLevelTime time.Time
+}
+
+// NewLevelMeter starts an FFmpeg decoder for the given source content type
+func NewLevelMeter(contentType string) (*LevelMeter, error) {
+	ffmpegPath, err := GetFFmpegPath()
+	if err != nil {
+		return nil, err
+	}
+
+	ffmpegArgs := []string{"-hide_banner", "-loglevel", "error"}
+	switch contentType {
+	case "audio/mpeg":
+		ffmpegArgs = append(ffmpegArgs, "-f", "mp3")
+	case "audio/ogg":
+		ffmpegArgs = append(ffmpegArgs, "-f", "ogg")
+	}
+	ffmpegArgs = append(ffmpegArgs,
+		"-i", "pipe:0", // Read live chunks from stdin
+		"-ac", "1", // Mono is enough for level detection
+		"-ar", "8000", // Low sample rate keeps the meter cheap
+		"-f", "s16le", // Raw 16-bit PCM
+		"pipe:1",
+	)
+
+	cmd := exec.Command(ffmpegPath, ffmpegArgs...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %v", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return nil, fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		stdin.Close()
+		stdout.Close()
+		return nil, fmt.Errorf("failed to start ffmpeg process: %v", err)
+	}
+
+	meter := &LevelMeter{
+		cmd:       cmd,
+		stdin:     stdin,
+		stdout:    stdout,
+		input:     make(chan []byte, 64),
+		isRunning: true,
+		levelDb:   levelMeterFloorDb,
+	}
+
+	go meter.writeLoop()
+	go meter.readLoop()
+
+	return meter, nil
+}
+
+// Write queues a chunk for decoding, dropping it if the decoder has fallen behind
+func (lm *LevelMeter) Write(data []byte) {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+
+	if !lm.isRunning {
+		return
+	}
+
+	select {
+	case lm.input <- data:
+	default:
+		Logger.Debug("Level meter backlog full, dropping chunk")
+	}
+}
+
+func (lm *LevelMeter) writeLoop() {
+	for data := range lm.input {
+		if _, err := lm.stdin.Write(data); err != nil {
+			Logger.Debug(fmt.Sprintf("Level meter write failed: %v", err))
+			return
+		}
+	}
+}
+
+// readLoop computes the RMS level over half-second windows of decoded PCM
+func (lm *LevelMeter) readLoop() {
+	const windowSamples = 4000 // 0.5s at 8kHz
+	buffer := make([]byte, windowSamples*2)
+
+	for {
+		if _, err := io.ReadFull(lm.stdout, buffer); err != nil {
+			lm.mu.Lock()
+			lm.exited = true
+			lm.mu.Unlock()
+			lm.Close()
+			return
+		}
+
+		var sum float64
+		for i := 0; i < windowSamples; i++ {
+			sample := float64(int16(binary.LittleEndian.Uint16(buffer[i*2:])))
+			sum += sample * sample
+		}
+		rms := math.Sqrt(sum / windowSamples)
+
+		levelDb := levelMeterFloorDb
+		if rms > 0 {
+			levelDb = 20 * math.Log10(rms/32768)
+		}
+		if levelDb < levelMeterFloorDb {
+			levelDb = levelMeterFloorDb
+		}
+
+		lm.mu.Lock()
+		lm.levelDb = levelDb
+		lm.lastLevelTime = time.Now()
+		lm.mu.Unlock()
+	}
+}
+
+// Level returns the last measured level in dBFS and when it was measured
+func (lm *LevelMeter) Level() (float64, time.Time) {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+	return lm.levelDb, lm.lastLevelTime
+}
+
+// Exited reports whether the decoder process has stopped
+func (lm *LevelMeter) Exited() bool {
+	lm.mu.RLock()
+	defer lm.mu.RUnlock()
+	return lm.exited
+}
+
+// Close stops the decoder process
+func (lm *LevelMeter) Close() {
+	lm.mu.Lock()
+	if !lm.isRunning {
+		lm.mu.Unlock()
+		return
+	}
+	lm.isRunning = false
+	close(lm.input)
+	lm.mu.Unlock()
+
+	lm.stdin.Close()
+	if lm.cmd.Process != nil {
+		lm.cmd.Process.Kill()
+		lm.cmd.Wait()
+	}
+}
+
+// WatchdogEvent records a dead-air fallback or recovery
+type WatchdogEvent struct {
+	Time    int64   `json:"time"`
+	Event   string  `json:"event"`  // "fallback" or "recovered"
+	Reason  string  `json:"reason"` // "silence" or "stall"
+	LevelDb float64 `json:"level_db"`
+}
+
+// WatchdogStatus is a snapshot of the watchdog state for the API
+type WatchdogStatus struct {
+	Enabled          bool            `json:"enabled"`
+	Tripped          bool            `json:"tripped"`
+	Reason           string          `json:"reason"`
+	LevelDb          float64         `json:"level_db"`
+	LevelMetering    bool            `json:"level_metering"`
+	SecondsSinceData float64         `json:"seconds_since_data"`
+	Events           []WatchdogEvent `json:"events"`
+}
+
+// ILiveWatchdog detects dead air (silence or stalls) on a connected live source
+type ILiveWatchdog struct {
+	mu           sync.Mutex
+	meter        *LevelMeter
+	contentType  string // Of the connected source, to restart the meter
+	meterStarted time.Time
+	silentSince  time.Time
+	audibleSince time.Time
+	tripped      bool
+	reason       string
+	events       []WatchdogEvent
+}
+
+// Maximum number of watchdog events kept for the API
+const maxWatchdogEvents = 20
+
+// Minimum time between starts of the level meter, so a crashing FFmpeg isn't respawned on every check
+const levelMeterRestartDelay = 10 * time.Second
+
+var LiveWatchdog = &ILiveWatchdog{}
+
+// Start resets the watchdog for a newly connected source and starts level metering
+func (w *ILiveWatchdog) Start(contentType string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.resetLocked()
+	if !Config.DeadAirEnabled {
+		return
+	}
+
+	w.contentType = contentType
+	w.startMeterLocked(time.Now())
+}
+
+func (w *ILiveWatchdog) startMeterLocked(now time.Time) {
+	w.meterStarted = now
+	meter, err := NewLevelMeter(w.contentType)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Dead-air level metering unavailable, only stalls will be detected: %v", err))
+		return
+	}
+	w.meter = meter
+}
+
+// Stop releases the level meter when the source disconnects
+func (w *ILiveWatchdog) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resetLocked()
+}
+
+func (w *ILiveWatchdog) resetLocked() {
+	if w.meter != nil {
+		w.meter.Close()
+		w.meter = nil
+	}
+	w.contentType = ""
+	w.silentSince = time.Time{}
+	w.audibleSince = time.Time{}
+	w.tripped = false
+	w.reason = ""
+}
+
+// Observe feeds a live chunk to the level meter
+func (w *ILiveWatchdog) Observe(chunk []byte) {
+	w.mu.Lock()
+	meter := w.meter
+	w.mu.Unlock()
+
+	if meter != nil {
+		meter.Write(chunk)
+	}
+}
+
+// Healthy evaluates the thresholds and reports whether the live source should be on air
+// Transitions are logged and recorded as events
+func (w *ILiveWatchdog) Healthy() bool {
+	if !Config.DeadAirEnabled {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	stallLimit := time.Duration(Config.DeadAirStallSeconds) * time.Second
+	stalled := now.Sub(IcecastSource.LastDataTime()) > stallLimit
+
+	// A meter whose FFmpeg exited would never measure again; start a new one
+	if w.meter != nil && w.meter.Exited() && now.Sub(w.meterStarted) >= levelMeterRestartDelay {
+		Logger.Error("Dead-air level meter stopped - restarting it")
+		w.meter = nil
+		w.startMeterLocked(now)
+	}
+
+	// Level is only trusted while the meter keeps producing fresh measurements
+	levelDb := levelMeterFloorDb
+	silent := false
+	metering := false
+	if w.meter != nil {
+		level, measuredAt := w.meter.Level()
+		if !measuredAt.IsZero() && now.Sub(measuredAt) < 2*time.Second {
+			metering = true
+			levelDb = level
+			silent = level < Config.DeadAirThresholdDb
+		}
+	}
+
+	if silent {
+		if w.silentSince.IsZero() {
+			w.silentSince = now
+		}
+		w.audibleSince = time.Time{}
+	} else {
+		w.silentSince = time.Time{}
+		if !stalled && (metering || w.meter == nil) && w.audibleSince.IsZero() {
+			w.audibleSince = now
+		}
+	}
+	if stalled {
+		w.audibleSince = time.Time{}
+	}
+
+	if !w.tripped {
+		reason := ""
+		silenceLimit := time.Duration(Config.DeadAirSilenceSeconds) * time.Second
+		if stalled {
+			reason = "stall"
+		} else if !w.silentSince.IsZero() && now.Sub(w.silentSince) > silenceLimit {
+			reason = "silence"
+		}
+		if reason != "" {
+			w.tripped = true
+			w.reason = reason
+			w.recordLocked("fallback", reason, levelDb)
+			Logger.Info(fmt.Sprintf("Dead air detected on live source (%s, level %.1f dBFS) - falling back to automation", reason, levelDb))
+		}
+		return !w.tripped
+	}
+
+	resumeLimit := time.Duration(Config.DeadAirResumeSeconds) * time.Second
+	if !w.audibleSince.IsZero() && now.Sub(w.audibleSince) >= resumeLimit {
+		Logger.Info(fmt.Sprintf("Live source audio resumed (level %.1f dBFS) - returning to live", levelDb))
+		w.recordLocked("recovered", w.reason, levelDb)
+		w.tripped = false
+		w.reason = ""
+	}
+	return !w.tripped
+}
+
+func (w *ILiveWatchdog) recordLocked(event, reason string, levelDb float64) {
+	w.events = append(w.events, WatchdogEvent{
+		Time:    time.Now().UnixMilli(),
+		Event:   event,
+		Reason:  reason,
+		LevelDb: levelDb,
+	})
+	if len(w.events) > maxWatchdogEvents {
+		w.events = w.events[len(w.events)-maxWatchdogEvents:]
+	}
+}
+
+// Status returns a snapshot of the watchdog state
+func (w *ILiveWatchdog) Status() WatchdogStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := WatchdogStatus{
+		Enabled: Config.DeadAirEnabled,
+		Tripped: w.tripped,
+		Reason:  w.reason,
+		LevelDb: levelMeterFloorDb,
+		Events:  make([]WatchdogEvent, len(w.events)),
+	}
+	copy(status.Events, w.events)
+
+	if w.meter != nil && !w.meter.Exited() {
+		status.LevelMetering = true
+		status.LevelDb, _ = w.meter.Level()
+	}
+	if IcecastSource.HasActiveSource() {
+		status.SecondsSinceData = time.Since(IcecastSource.LastDataTime()).Seconds()
+	}
+	return status
+}
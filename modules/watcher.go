@@ -0,0 +1,94 @@
+package modules
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// How long the watcher waits for more events before updating the library, so that
+// a copy in progress or a batch of moves is indexed once
+const libraryWatchDelay = time.Second
+
+// libraryWatcher collects changed paths of the music directory and updates the library
+type libraryWatcher struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	rescan  bool // Events were lost; rescan the whole directory
+	timer   *time.Timer
+}
+
+var libraryChanges = &libraryWatcher{
+	pending: make(map[string]bool),
+}
+
+// changed queues a created, modified, moved or deleted path
+func (w *libraryWatcher) changed(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[path] = true
+	w.scheduleLocked()
+}
+
+// lost queues a full rescan after the event queue overflowed
+func (w *libraryWatcher) lost() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rescan = true
+	w.scheduleLocked()
+}
+
+func (w *libraryWatcher) scheduleLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(libraryWatchDelay, w.flush)
+}
+
+func (w *libraryWatcher) flush() {
+	w.mu.Lock()
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	rescan := w.rescan
+	w.pending = make(map[string]bool)
+	w.rescan = false
+	w.mu.Unlock()
+
+	var err error
+	if rescan {
+		_, err = Library.Refresh()
+	} else if len(paths) > 0 {
+		_, err = Library.Update(paths)
+	}
+	if err != nil {
+		Logger.Error(fmt.Sprintf("Library update failed: %v", err))
+	}
+}
+
+// StartLibraryWatcher keeps the library index up to date as files change.
+// Filesystem events are used where available, otherwise the directory is polled.
+func StartLibraryWatcher() {
+	err := watchDirectory(Config.Directory, libraryChanges.changed, libraryChanges.lost)
+	if err == nil {
+		Logger.Info(fmt.Sprintf("Watching %s for library changes", Config.Directory))
+		return
+	}
+	if Config.LibraryPollSeconds <= 0 {
+		Logger.Info(fmt.Sprintf("Library watching unavailable (%v); polling disabled", err))
+		return
+	}
+	Logger.Info(fmt.Sprintf("Library watching unavailable (%v); polling every %d seconds", err, Config.LibraryPollSeconds))
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(Config.LibraryPollSeconds) * time.Second)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := Library.Refresh(); err != nil {
+				Logger.Error(fmt.Sprintf("Library poll failed: %v", err))
+			}
+		}
+	}()
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Events that change the songs of a directory. Files are indexed once they are
+// closed after writing, not when they are created.
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyWatcher watches a directory tree; inotify watches single directories,
+// so every subdirectory gets its own watch
+type inotifyWatcher struct {
+	fd      int
+	mu      sync.Mutex
+	dirs    map[int]string // Watch descriptor -> directory
+	changed func(path string)
+	lost    func()
+}
+
+// watchDirectory reports changes below root until the process exits
+func watchDirectory(root string, changed func(path string), lost func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("inotify: %v", err)
+	}
+
+	w := &inotifyWatcher{
+		fd:      fd,
+		dirs:    make(map[int]string),
+		changed: changed,
+		lost:    lost,
+	}
+	if err := w.addTree(filepath.Clean(root)); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	go w.run()
+	return nil
+}
+
+// addTree watches dir and all directories below it
+func (w *inotifyWatcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The directory may be gone already; its removal is reported separately
+			if path != dir {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask|syscall.IN_ONLYDIR)
+		if err != nil {
+			return fmt.Errorf("inotify watch %s: %v", path, err)
+		}
+		w.mu.Lock()
+		w.dirs[wd] = path
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			Logger.Error(fmt.Sprintf("Library watcher stopped: %v", err))
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+			w.handle(int(event.Wd), event.Mask, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) handle(wd int, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.lost()
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.dirs[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+	}
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+		// Reported by the parent directory as well; the root has no parent
+		if dir == filepath.Clean(Config.Directory) {
+			w.lost()
+		}
+		return
+	}
+	if name == "" {
+		return
+	}
+
+	path := filepath.Join(dir, name)
+	isDir := mask&syscall.IN_ISDIR != 0
+	if isDir && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		if err := w.addTree(path); err != nil {
+			Logger.Error(err)
+		}
+		w.changed(path)
+		return
+	}
+	// A new file is indexed when it has been written
+	if !isDir && mask&syscall.IN_CREATE != 0 {
+		return
+	}
+	w.changed(path)
+}
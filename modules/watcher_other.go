@@ -0,0 +1,10 @@
+//go:build !linux
+
+package modules
+
+import "fmt"
+
+// watchDirectory is only implemented with inotify on Linux
+func watchDirectory(root string, changed func(path string), lost func()) error {
+	return fmt.Errorf("filesystem events are not supported on this platform")
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"fmt"
+	"gostream/modules"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// parseTimeParam parses a time given as unix seconds, unix milliseconds or RFC3339
+func parseTimeParam(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Values beyond year 2286 in seconds are treated as milliseconds
+		if number > 9999999999 {
+			return time.UnixMilli(number), nil
+		}
+		return time.Unix(number, 0), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use unix seconds, milliseconds or RFC3339)", value)
+	}
+	return parsed, nil
+}
+
+// GetAircheck returns the aircheck segments and play log for a time range
+// Query: from, to (default: the last hour)
+func GetAircheck(ctx echo.Context) error {
+	now := time.Now()
+	to, err := parseTimeParam(ctx.QueryParam("to"), now)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	from, err := parseTimeParam(ctx.QueryParam("from"), to.Add(-time.Hour))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if !from.Before(to) {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "from must be before to",
+		})
+	}
+
+	segments, err := modules.AircheckSegments(from, to)
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Could not read aircheck segments",
+		})
+	}
+	plays, err := modules.AircheckLog(from, to)
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Could not read aircheck log",
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"enabled":  modules.Config.AircheckEnabled,
+		"from":     from.UnixMilli(),
+		"to":       to.UnixMilli(),
+		"segments": segments,
+		"plays":    plays,
+	})
+}
+
+// DownloadAircheck sends an aircheck segment
+func DownloadAircheck(ctx echo.Context) error {
+	name := ctx.Param("name")
+
+	path, err := modules.AircheckPath(name)
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Attachment(path, name)
+}
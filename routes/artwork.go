@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"errors"
+	"gostream/modules"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetArtwork serves the artwork of a song as JPEG; ?size= sets the edge length in pixels, rounded up to a cached size
+func GetArtwork(ctx echo.Context) error {
+	return serveArtwork(ctx, ctx.Param("hash"))
+}
+
+// GetCurrentArtwork serves the artwork of the song on air
+func GetCurrentArtwork(ctx echo.Context) error {
+	return serveArtwork(ctx, modules.MusicReader.CurrentSongHash)
+}
+
+func serveArtwork(ctx echo.Context, hash string) error {
+	size := 0
+	if param := ctx.QueryParam("size"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "size must be a positive number",
+			})
+		}
+		size = parsed
+	}
+
+	path, err := modules.Artwork(hash, size)
+	if errors.Is(err, modules.ErrSongNotFound) || errors.Is(err, modules.ErrNoArtwork) {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "failed to load artwork",
+		})
+	}
+
+	return ctx.File(path)
+}
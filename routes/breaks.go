@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetBreaks returns the configured breaks and the play counts of every spot
+func GetBreaks(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"breaks": modules.Config.Breaks,
+		"spots":  modules.AdBreaks.Status(),
+	})
+}
+
+// TriggerBreak queues a break to air after the current song
+func TriggerBreak(ctx echo.Context) error {
+	spots, err := modules.AdBreaks.TriggerBreak(ctx.Param("name"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	if len(spots) == 0 {
+		return ctx.JSON(http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "No eligible spots - break skipped",
+			"spots":   spots,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Break queued after the current song",
+		"spots":   spots,
+	})
+}
+
+// GetProofOfPlay returns the spots aired in a time range
+// Query: from, to (default: the current month), spot (optional)
+func GetProofOfPlay(ctx echo.Context) error {
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	from, err := parseTimeParam(ctx.QueryParam("from"), monthStart)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	to, err := parseTimeParam(ctx.QueryParam("to"), now)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	plays, err := modules.ProofOfPlay(from, to, ctx.QueryParam("spot"))
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Could not read proof-of-play log",
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"from":   from.UnixMilli(),
+		"to":     to.UnixMilli(),
+		"count":  len(plays),
+		"plays":  plays,
+	})
+}
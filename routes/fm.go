@@ -11,6 +11,14 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// metadataURL returns the StreamUrl of a song: its absolute artwork URL when it has artwork
+func metadataURL(ctx echo.Context, musicInfo *modules.IMusicInfo) string {
+	if musicInfo.Artwork == "" {
+		return musicInfo.Url
+	}
+	return ctx.Scheme() + "://" + ctx.Request().Host + musicInfo.Artwork
+}
+
 // BuildIcecastMetadata creates an ICY metadata block according to Shoutcast protocol
 // Metadata format: [1-byte-length][metadata-string][padding]
 // Length is in 16-byte chunks, not bytes
@@ -134,7 +142,7 @@ func GetFMStream(ctx echo.Context) error {
 					// File mode - send current song metadata
 					musicInfo := modules.MusicReader.GetMusicInfo()
 					if musicInfo != nil && musicInfo.Filename != "" {
-						metadata = BuildIcecastMetadata(musicInfo.Filename, musicInfo.Url)
+						metadata = BuildIcecastMetadata(musicInfo.Filename, metadataURL(ctx, musicInfo))
 					}
 				}
 				if len(metadata) > 0 {
@@ -210,7 +218,7 @@ func GetFMStream(ctx echo.Context) error {
 						// File mode - send current song metadata
 						musicInfo := modules.MusicReader.GetMusicInfo()
 						if musicInfo != nil && musicInfo.Filename != "" {
-							metadata = BuildIcecastMetadata(musicInfo.Filename, musicInfo.Url)
+							metadata = BuildIcecastMetadata(musicInfo.Filename, metadataURL(ctx, musicInfo))
 						}
 					}
 					if len(metadata) > 0 {
@@ -0,0 +1,286 @@
+package routes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"gostream/modules"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Largest page of history entries returned at once
+const maxHistoryLimit = 500
+
+// Longest time range /history returns
+const maxHistoryRange = 31 * 24 * time.Hour
+
+// Item types shown in the feeds unless the type parameter says otherwise
+var defaultFeedTypes = []string{modules.OnAirTrack, modules.OnAirLive}
+
+// parseCountParam parses a non-negative number query parameter
+func parseCountParam(ctx echo.Context, name string, fallback int) (int, error) {
+	value := ctx.QueryParam(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number", name)
+	}
+	return parsed, nil
+}
+
+// parseTypesParam parses a comma-separated list of on-air item types
+func parseTypesParam(value string, fallback []string) (map[string]bool, error) {
+	names := fallback
+	if value != "" {
+		names = strings.Split(value, ",")
+	}
+
+	types := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(strings.ToLower(name))
+		switch name {
+		case modules.OnAirTrack, modules.OnAirLive, modules.OnAirJingle, modules.OnAirSpot:
+			types[name] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown type %q (use track, live, jingle or spot)", name)
+		}
+	}
+	return types, nil
+}
+
+// GetHistory returns the items aired in a time range, newest first
+// Query: from, to (default: the last 24 hours), type (comma-separated, default all),
+// limit (default 50) and offset for pagination
+func GetHistory(ctx echo.Context) error {
+	now := time.Now()
+	to, err := parseTimeParam(ctx.QueryParam("to"), now)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	from, err := parseTimeParam(ctx.QueryParam("from"), to.Add(-24*time.Hour))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if !from.Before(to) {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "from must be before to",
+		})
+	}
+	if to.Sub(from) > maxHistoryRange {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "the time range must be at most 31 days",
+		})
+	}
+
+	types, err := parseTypesParam(ctx.QueryParam("type"), nil)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	limit, err := parseCountParam(ctx, "limit", 50)
+	if err == nil && (limit == 0 || limit > maxHistoryLimit) {
+		err = fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	offset, err := parseCountParam(ctx, "offset", 0)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	entries, err := modules.HistoryEntries(from, to, types)
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Could not read play history",
+		})
+	}
+
+	total := len(entries)
+	page := []modules.HistoryEntry{}
+	if offset < total {
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		page = entries[offset:end]
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"from":   from.UnixMilli(),
+		"to":     to.UnixMilli(),
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+		"items":  page,
+	})
+}
+
+// recentHistory returns the most recent entries for the feeds
+// Query: type (comma-separated, default track and live), limit (default 20)
+func recentHistory(ctx echo.Context) ([]modules.HistoryEntry, error) {
+	types, err := parseTypesParam(ctx.QueryParam("type"), defaultFeedTypes)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseCountParam(ctx, "limit", 20)
+	if err == nil && (limit == 0 || limit > maxHistoryLimit) {
+		err = fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Look back a week; a widget only needs the last few items
+	now := time.Now()
+	entries, err := modules.HistoryEntries(now.AddDate(0, 0, -7), now, types)
+	if err != nil {
+		modules.Logger.Error(err)
+		return nil, fmt.Errorf("could not read play history")
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func historyItemTitle(entry modules.HistoryEntry) string {
+	if entry.Artist != "" {
+		return entry.Artist + " - " + entry.Title
+	}
+	if entry.Title != "" {
+		return entry.Title
+	}
+	return entry.Filename
+}
+
+func historyItemID(entry modules.HistoryEntry) string {
+	return fmt.Sprintf("%s-%d", entry.Type, entry.Start)
+}
+
+// GetHistoryFeed returns recently played items as a JSON Feed (https://jsonfeed.org)
+func GetHistoryFeed(ctx echo.Context) error {
+	entries, err := recentHistory(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	baseURL := ctx.Scheme() + "://" + ctx.Request().Host
+	items := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		item := map[string]interface{}{
+			"id":             historyItemID(entry),
+			"title":          historyItemTitle(entry),
+			"content_text":   historyItemTitle(entry),
+			"date_published": time.UnixMilli(entry.Start).Format(time.RFC3339),
+			"_gostream":      entry,
+		}
+		if entry.Artist != "" {
+			item["authors"] = []map[string]string{{"name": entry.Artist}}
+		}
+		items = append(items, item)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentType, "application/feed+json; charset=utf-8")
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"version":       "https://jsonfeed.org/version/1.1",
+		"title":         modules.Config.Name + " - Recently played",
+		"home_page_url": baseURL + "/",
+		"feed_url":      baseURL + ctx.Request().URL.RequestURI(),
+		"items":         items,
+	})
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title    string  `xml:"title"`
+	GUID     rssGUID `xml:"guid"`
+	PubDate  string  `xml:"pubDate"`
+	Category string  `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// GetHistoryRSS returns recently played items as an RSS 2.0 feed
+func GetHistoryRSS(ctx echo.Context) error {
+	entries, err := recentHistory(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         modules.Config.Name + " - Recently played",
+			Link:          ctx.Scheme() + "://" + ctx.Request().Host + "/",
+			Description:   "Songs and shows recently played on " + modules.Config.Name,
+			LastBuildDate: time.Now().Format(time.RFC1123Z),
+		},
+	}
+	for _, entry := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:    historyItemTitle(entry),
+			GUID:     rssGUID{Value: historyItemID(entry)},
+			PubDate:  time.UnixMilli(entry.Start).Format(time.RFC1123Z),
+			Category: entry.Type,
+		})
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Could not build feed",
+		})
+	}
+	return ctx.Blob(http.StatusOK, "application/rss+xml; charset=utf-8", append([]byte(xml.Header), data...))
+}
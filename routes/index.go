@@ -18,6 +18,18 @@ func InitRoutes(e *echo.Echo) {
 	e.GET("/songs", GetSongsList)
 	e.GET("/metrics", GetMetrics)
 	e.GET("/mode", GetStreamMode)
+	e.GET("/schedule", GetSchedule)
+	e.GET("/clock", GetClock)
+	e.POST("/request", SubmitRequest)
+	e.GET("/vote/skip", GetSkipVotes)
+	e.POST("/vote/skip", VoteSkip)
+	e.POST("/like", LikeSong)
+	e.POST("/dislike", DislikeSong)
+	e.GET("/history", GetHistory)
+	e.GET("/history/feed.json", GetHistoryFeed)
+	e.GET("/history/rss", GetHistoryRSS)
+	e.GET("/art/current", GetCurrentArtwork)
+	e.GET("/art/:hash", GetArtwork)
 	
 	// Protected endpoints - require authentication
 	e.GET("/skip", SkipSong, middlewares.BasicAuth)
@@ -30,10 +42,65 @@ func InitRoutes(e *echo.Echo) {
 	e.DELETE("/playlist", ClearPlaylist, middlewares.BasicAuth)
 	e.POST("/playlist/reorder", ReorderPlaylist, middlewares.BasicAuth)
 	
+	// Named playlists - protected
+	e.GET("/playlists", GetPlaylists, middlewares.BasicAuth)
+	e.POST("/playlists", CreatePlaylist, middlewares.BasicAuth)
+	e.DELETE("/playlists/rotation", DeactivatePlaylist, middlewares.BasicAuth)
+	e.GET("/playlists/:name", GetNamedPlaylist, middlewares.BasicAuth)
+	e.DELETE("/playlists/:name", DeletePlaylist, middlewares.BasicAuth)
+	e.POST("/playlists/:name/rename", RenamePlaylist, middlewares.BasicAuth)
+	e.POST("/playlists/:name/add", AddToNamedPlaylist, middlewares.BasicAuth)
+	e.DELETE("/playlists/:name/remove", RemoveFromNamedPlaylist, middlewares.BasicAuth)
+	e.POST("/playlists/:name/reorder", ReorderNamedPlaylist, middlewares.BasicAuth)
+	e.POST("/playlists/:name/load", LoadPlaylistIntoQueue, middlewares.BasicAuth)
+	e.POST("/playlists/:name/activate", ActivatePlaylist, middlewares.BasicAuth)
+	e.POST("/playlists/:name/import", ImportPlaylist, middlewares.BasicAuth)
+	e.GET("/playlists/:name/export", ExportPlaylist, middlewares.BasicAuth)
+	
+	// Smart playlists - protected
+	e.GET("/smart-playlists", GetSmartPlaylists, middlewares.BasicAuth)
+	e.GET("/smart-playlists/preview", PreviewSmartRule, middlewares.BasicAuth)
+	e.GET("/smart-playlists/:name/preview", PreviewSmartPlaylist, middlewares.BasicAuth)
+	e.POST("/smart-playlists/:name/activate", ActivateSmartPlaylist, middlewares.BasicAuth)
+	
+	// Library index - protected
+	e.GET("/library", GetLibraryStatus, middlewares.BasicAuth)
+	e.POST("/library/rescan", RescanLibrary, middlewares.BasicAuth)
+	
+	// Uploads - protected
+	e.POST("/songs", UploadSong, middlewares.BasicAuth)
+	e.GET("/songs/:hash/ingest", GetIngestStatus, middlewares.BasicAuth)
+	e.GET("/uploads", GetUploads, middlewares.BasicAuth)
+	
+	// Song management - protected
+	e.PATCH("/songs/:hash", EditSong, middlewares.BasicAuth)
+	e.POST("/songs/:hash/move", MoveSong, middlewares.BasicAuth)
+	e.DELETE("/songs/:hash", DeleteSong, middlewares.BasicAuth)
+	
+	// Listener request moderation - protected
+	e.GET("/requests", GetRequests, middlewares.BasicAuth)
+	e.POST("/requests/:id/approve", ApproveRequest, middlewares.BasicAuth)
+	e.POST("/requests/:id/reject", RejectRequest, middlewares.BasicAuth)
+	e.POST("/requests/auto-approve", SetRequestAutoApprove, middlewares.BasicAuth)
+	
 	// Icecast mode endpoints - protected (for manual override only - automatic switching is enabled)
 	e.POST("/icecast/enable", EnableIcecastMode, middlewares.BasicAuth)
 	e.POST("/icecast/disable", DisableIcecastMode, middlewares.BasicAuth)
 	
+	// Live show recordings - protected
+	e.GET("/recordings", GetRecordings, middlewares.BasicAuth)
+	e.GET("/recordings/:name", DownloadRecording, middlewares.BasicAuth)
+	e.DELETE("/recordings/:name", DeleteRecording, middlewares.BasicAuth)
+	
+	// Ad breaks and proof of play - protected
+	e.GET("/breaks", GetBreaks, middlewares.BasicAuth)
+	e.POST("/breaks/:name", TriggerBreak, middlewares.BasicAuth)
+	e.GET("/proof-of-play", GetProofOfPlay, middlewares.BasicAuth)
+	
+	// Aircheck (compliance log) - protected
+	e.GET("/aircheck", GetAircheck, middlewares.BasicAuth)
+	e.GET("/aircheck/:name", DownloadAircheck, middlewares.BasicAuth)
+	
 	e.GET("/favicon.ico", func(c echo.Context) error {
         return c.NoContent(http.StatusNoContent)
     })
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetLibraryStatus returns the size of the library index and when it was last scanned
+func GetLibraryStatus(ctx echo.Context) error {
+	entries := modules.Library.Entries()
+	duration := int64(0)
+	for _, entry := range entries {
+		duration += entry.Duration
+	}
+
+	lastScan := int64(0)
+	if scanned := modules.Library.LastScan(); !scanned.IsZero() {
+		lastScan = scanned.UnixMilli()
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":         "success",
+		"total":          len(entries),
+		"duration":       duration,
+		"last_scan":      lastScan,
+		"rescan_minutes": modules.Config.LibraryRescanMinutes,
+	})
+}
+
+// RescanLibrary rescans the music directory now
+func RescanLibrary(ctx echo.Context) error {
+	scan, err := modules.Library.Refresh()
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "library scan failed",
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"scan":   scan,
+	})
+}
@@ -0,0 +1,329 @@
+package routes
+
+import (
+	"fmt"
+	"gostream/modules"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Largest playlist file accepted for import
+const maxPlaylistImportSize = 5 << 20
+
+// playlistName returns the unescaped :name path parameter
+func playlistName(ctx echo.Context) string {
+	name, err := url.PathUnescape(ctx.Param("name"))
+	if err != nil {
+		return ctx.Param("name")
+	}
+	return name
+}
+
+func playlistError(ctx echo.Context, err error) error {
+	status := http.StatusBadRequest
+	if err.Error() == "playlist not found" {
+		status = http.StatusNotFound
+	}
+	return ctx.JSON(status, map[string]interface{}{
+		"status":  "error",
+		"message": err.Error(),
+	})
+}
+
+// GetPlaylists lists the named playlists and the active rotation playlist
+func GetPlaylists(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":       "success",
+		"active":       modules.Playlists.Active(),
+		"active_smart": modules.Playlists.ActiveSmart(),
+		"playlists":    modules.Playlists.List(),
+	})
+}
+
+// CreatePlaylist creates an empty named playlist
+// Params: name
+func CreatePlaylist(ctx echo.Context) error {
+	name := ctx.FormValue("name")
+	if err := modules.Playlists.Create(name); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "playlist created",
+	})
+}
+
+// GetNamedPlaylist returns the songs of a playlist
+func GetNamedPlaylist(ctx echo.Context) error {
+	playlist, err := modules.Playlists.Get(playlistName(ctx))
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+
+	type PlaylistSong struct {
+		Index    int    `json:"index"`
+		Hash     string `json:"hash,omitempty"`
+		Title    string `json:"title"`
+		Artist   string `json:"artist"`
+		Filename string `json:"filename"`
+		Path     string `json:"path"`
+		Missing  bool   `json:"missing,omitempty"` // The file no longer exists
+	}
+
+	songs := []PlaylistSong{}
+	for i, song := range playlist.Songs {
+		path := song
+		if !filepath.IsAbs(filepath.FromSlash(path)) {
+			path = filepath.Join(modules.Config.Directory, filepath.FromSlash(song))
+		}
+		item := PlaylistSong{Index: i, Path: song, Filename: filepath.Base(path), Title: filepath.Base(path), Artist: "Unknown"}
+		if _, err := os.Stat(path); err != nil {
+			item.Missing = true
+		} else {
+			item.Hash = modules.Library.HashForPath(path)
+			modules.SongHashMap.Store(item.Hash, path)
+			if meta, ok := modules.GetTrackMeta(item.Hash); ok {
+				item.Title = meta.Title
+				if meta.Artist != "" {
+					item.Artist = meta.Artist
+				}
+			}
+		}
+		songs = append(songs, item)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"name":    playlist.Name,
+		"active":  playlist.Name == modules.Playlists.Active(),
+		"created": playlist.Created,
+		"updated": playlist.Updated,
+		"total":   len(songs),
+		"songs":   songs,
+	})
+}
+
+// DeletePlaylist deletes a playlist
+func DeletePlaylist(ctx echo.Context) error {
+	if err := modules.Playlists.Delete(playlistName(ctx)); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "playlist deleted",
+	})
+}
+
+// RenamePlaylist renames a playlist
+// Params: to (new name)
+func RenamePlaylist(ctx echo.Context) error {
+	if err := modules.Playlists.Rename(playlistName(ctx), ctx.FormValue("to")); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "playlist renamed",
+	})
+}
+
+// AddToNamedPlaylist adds a song to a playlist
+// Params: hash, index (optional position, default: the end)
+func AddToNamedPlaylist(ctx echo.Context) error {
+	hash := ctx.FormValue("hash")
+	if hash == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "hash parameter is required",
+		})
+	}
+	index := -1
+	if value := ctx.FormValue("index"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "index must be a valid integer",
+			})
+		}
+		index = parsed
+	}
+
+	if err := modules.Playlists.Add(playlistName(ctx), hash, index); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "song added to playlist",
+	})
+}
+
+// RemoveFromNamedPlaylist removes a song from a playlist by position (0-indexed)
+// Params: index
+func RemoveFromNamedPlaylist(ctx echo.Context) error {
+	index, err := strconv.Atoi(ctx.FormValue("index"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "index must be a valid integer",
+		})
+	}
+	if err := modules.Playlists.Remove(playlistName(ctx), index); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "song removed from playlist",
+	})
+}
+
+// ReorderNamedPlaylist moves a song within a playlist
+// Params: from, to (0-indexed positions)
+func ReorderNamedPlaylist(ctx echo.Context) error {
+	moveFrom, errFrom := strconv.Atoi(ctx.FormValue("from"))
+	moveTo, errTo := strconv.Atoi(ctx.FormValue("to"))
+	if errFrom != nil || errTo != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "from and to must be valid integers",
+		})
+	}
+	if err := modules.Playlists.Reorder(playlistName(ctx), moveFrom, moveTo); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "playlist reordered",
+	})
+}
+
+// LoadPlaylistIntoQueue adds the songs of a playlist to the playout queue
+// Params: replace (true clears the queue first)
+func LoadPlaylistIntoQueue(ctx echo.Context) error {
+	replace := ctx.FormValue("replace") == "true"
+	count, err := modules.Playlists.LoadIntoQueue(playlistName(ctx), replace)
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": fmt.Sprintf("%d songs added to the queue", count),
+		"added":   count,
+	})
+}
+
+// ActivatePlaylist makes a playlist the rotation source instead of the whole library
+func ActivatePlaylist(ctx echo.Context) error {
+	if err := modules.Playlists.SetActive(playlistName(ctx)); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "playlist is now the rotation source",
+	})
+}
+
+// DeactivatePlaylist returns rotation from a named or smart playlist to the whole library
+func DeactivatePlaylist(ctx echo.Context) error {
+	if err := modules.Playlists.SetActive(""); err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "rotation returned to the library",
+	})
+}
+
+// ImportPlaylist replaces the songs of a playlist (creating it if needed) with an M3U/M3U8/PLS file
+// Body: multipart field "file", or the raw playlist; format from the file name or the format parameter
+// Relative entries are resolved against the music directory
+func ImportPlaylist(ctx echo.Context) error {
+	var data []byte
+	filename := ctx.QueryParam("format")
+
+	if strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "file field is required",
+			})
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return playlistError(ctx, err)
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(io.LimitReader(file, maxPlaylistImportSize)); err != nil {
+			return playlistError(ctx, err)
+		}
+		if filename == "" {
+			filename = fileHeader.Filename
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(io.LimitReader(ctx.Request().Body, maxPlaylistImportSize)); err != nil {
+			return playlistError(ctx, err)
+		}
+	}
+	if filename == "" {
+		filename = modules.PlaylistFormatM3U
+	}
+
+	format, err := modules.PlaylistFormat(filename)
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+	paths, err := modules.ParsePlaylist(data, format, modules.Config.Directory)
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+	if len(paths) == 0 {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "no existing mp3 files found in the playlist",
+		})
+	}
+
+	imported, err := modules.Playlists.SetSongs(playlistName(ctx), paths)
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"message":  fmt.Sprintf("%d songs imported", imported),
+		"imported": imported,
+	})
+}
+
+// ExportPlaylist downloads a playlist as M3U/M3U8 or PLS
+// Query: format (m3u, m3u8 or pls, default m3u8)
+func ExportPlaylist(ctx echo.Context) error {
+	value := ctx.QueryParam("format")
+	if value == "" {
+		value = modules.PlaylistFormatM3U8
+	}
+	format, err := modules.PlaylistFormat(value)
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+
+	name := playlistName(ctx)
+	data, err := modules.Playlists.ExportPlaylist(name, format)
+	if err != nil {
+		return playlistError(ctx, err)
+	}
+
+	contentType := "audio/x-mpegurl"
+	if format == modules.PlaylistFormatPLS {
+		contentType = "audio/x-scpls"
+	}
+	ctx.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	return ctx.Blob(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetRecordings lists archived live show recordings
+func GetRecordings(ctx echo.Context) error {
+	recordings, err := modules.ListRecordings()
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Could not list recordings",
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":     "success",
+		"total":      len(recordings),
+		"recording":  modules.LiveRecorder.ActiveRecording(),
+		"recordings": recordings,
+	})
+}
+
+// DownloadRecording sends a recording (.mp3) or its cue sheet (.cue)
+func DownloadRecording(ctx echo.Context) error {
+	name := ctx.Param("name")
+
+	path, err := modules.RecordingPath(name)
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Attachment(path, name)
+}
+
+// DeleteRecording removes a recording and its cue sheet
+func DeleteRecording(ctx echo.Context) error {
+	name := ctx.Param("name")
+
+	err := modules.DeleteRecording(name)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "recording deleted",
+	})
+}
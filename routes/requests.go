@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Longest listener name and message accepted with a request
+const (
+	maxRequestNameLength    = 50
+	maxRequestMessageLength = 200
+)
+
+func truncate(value string, length int) string {
+	value = strings.TrimSpace(value)
+	if len([]rune(value)) > length {
+		return string([]rune(value)[:length])
+	}
+	return value
+}
+
+// listenerKey identifies a listener by IP. Client-chosen tokens would let one
+// client vote or request as many listeners; forwarded headers count only with trust_proxy.
+func listenerKey(ctx echo.Context) string {
+	return "ip:" + ctx.RealIP()
+}
+
+// SubmitRequest takes a song request from a listener
+// Params: hash (from /songs), name and message (optional)
+// Listeners are rate limited by IP
+func SubmitRequest(ctx echo.Context) error {
+	hash := ctx.FormValue("hash")
+	if hash == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "hash parameter is required",
+		})
+	}
+
+	request, err := modules.Requests.Submit(hash, listenerKey(ctx), truncate(ctx.FormValue("name"), maxRequestNameLength), truncate(ctx.FormValue("message"), maxRequestMessageLength))
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "request limit") {
+			status = http.StatusTooManyRequests
+		} else if err.Error() == "requests are disabled" {
+			status = http.StatusForbidden
+		}
+		return ctx.JSON(status, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	message := "Request received and waiting for approval"
+	if request.Status == modules.RequestApproved {
+		message = "Request accepted"
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": message,
+		"request": request,
+	})
+}
+
+// GetRequests returns the request queue
+// Query: status (optional filter)
+func GetRequests(ctx echo.Context) error {
+	filter := ctx.QueryParam("status")
+
+	requests := []modules.ListenerRequest{}
+	for _, request := range modules.Requests.List() {
+		if filter == "" || request.Status == filter {
+			requests = append(requests, request)
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":       "success",
+		"enabled":      modules.Config.RequestsEnabled,
+		"auto_approve": modules.Requests.AutoApprove(),
+		"total":        len(requests),
+		"requests":     requests,
+	})
+}
+
+// ApproveRequest approves a pending request
+func ApproveRequest(ctx echo.Context) error {
+	return moderateRequest(ctx, true)
+}
+
+// RejectRequest rejects a pending or approved request
+func RejectRequest(ctx echo.Context) error {
+	return moderateRequest(ctx, false)
+}
+
+func moderateRequest(ctx echo.Context, approve bool) error {
+	request, err := modules.Requests.Moderate(ctx.Param("id"), approve)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "request not found" {
+			status = http.StatusNotFound
+		}
+		return ctx.JSON(status, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"request": request,
+	})
+}
+
+// SetRequestAutoApprove switches automatic approval of new requests
+// Params: enabled (true/false)
+func SetRequestAutoApprove(ctx echo.Context) error {
+	enabled := ctx.FormValue("enabled")
+	if enabled != "true" && enabled != "false" {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "enabled must be true or false",
+		})
+	}
+
+	modules.Requests.SetAutoApprove(enabled == "true")
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":       "success",
+		"auto_approve": enabled == "true",
+	})
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetSchedule returns the programming slot on air now and the upcoming slots
+// Query: limit (number of upcoming slots, default 10)
+func GetSchedule(ctx echo.Context) error {
+	limit := 10
+	if value := ctx.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "limit must be a positive number",
+			})
+		}
+		limit = parsed
+	}
+
+	now := time.Now()
+	_, current := modules.ActiveScheduleSlot(now)
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"enabled":  len(modules.Config.Schedule) > 0,
+		"time":     now.UnixMilli(),
+		"current":  current,
+		"upcoming": modules.UpcomingScheduleSlots(now, limit),
+	})
+}
+
+// GetClock returns the clock wheel in use and the position within it
+func GetClock(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"selector": modules.ActiveSelectorKey(time.Now()),
+		"clock":    modules.ClockWheel.Status(),
+	})
+}
@@ -6,9 +6,10 @@ import (
 	"gostream/tools"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/bogem/id3v2/v2"
 	"github.com/labstack/echo/v4"
 )
 
@@ -80,7 +81,9 @@ func GetStreamStatus(ctx echo.Context) error {
 			"artist":     musicInfo.Artist,
 			"bitrate":    musicInfo.BitRate,
 			"samplerate": musicInfo.SampleRate,
+			"artwork":    musicInfo.Artwork,
 		},
+		"jingles": modules.Jingles.Status(),
 	})
 }
 
@@ -102,6 +105,7 @@ func GetNextSong(ctx echo.Context) error {
 			"artist":     nextInfo.Artist,
 			"bitrate":    nextInfo.BitRate,
 			"samplerate": nextInfo.SampleRate,
+			"artwork":    nextInfo.Artwork,
 		},
 	})
 }
@@ -168,13 +172,29 @@ func GetMetrics(ctx echo.Context) error {
 				"current_mbps": fmt.Sprintf("%.2f Mbps", metricsData.BandwidthMbps),
 				"raw_mbps":     metricsData.BandwidthMbps,
 			},
+			"transcode_cache": map[string]interface{}{
+				"hits":          metricsData.TranscodeCache.Hits,
+				"misses":        metricsData.TranscodeCache.Misses,
+				"pretranscodes": metricsData.TranscodeCache.Pretranscodes,
+				"evictions":     metricsData.TranscodeCache.Evictions,
+				"files":         metricsData.TranscodeCache.Files,
+				"size": map[string]interface{}{
+					"bytes": metricsData.TranscodeCache.SizeBytes,
+					"human": formatBytes(metricsData.TranscodeCache.SizeBytes),
+				},
+				"max_bytes": metricsData.TranscodeCache.MaxBytes,
+			},
 		},
 	})
 }
 
-// GetSongsList returns a list of all songs with their hash IDs
+// GetSongsList returns the songs of the library with their hash IDs
+// Query: q (words matched against title, artist, album and file name), genre,
+// year (1999 or 1990-1999), folder (relative to the music directory),
+// sort (title, artist, album, genre, year, duration, bitrate, added, path,
+// play_count or last_played; "-" prefix for descending), limit (default all) and offset
 func GetSongsList(ctx echo.Context) error {
-	mp3FilePaths, err := modules.GetMp3FilePaths()
+	_, err := modules.GetMp3FilePaths()
 	if err != nil {
 		modules.Logger.Error(err)
 		return ctx.JSON(http.StatusOK, map[string]interface{}{
@@ -183,56 +203,126 @@ func GetSongsList(ctx echo.Context) error {
 		})
 	}
 
-	type SongItem struct {
-		Hash     string `json:"hash"`
-		Title    string `json:"title"`
-		Artist   string `json:"artist"`
-		Filename string `json:"filename"`
+	query := modules.SongQuery{
+		Text:   ctx.QueryParam("q"),
+		Genre:  ctx.QueryParam("genre"),
+		Folder: ctx.QueryParam("folder"),
+		Sort:   ctx.QueryParam("sort"),
+	}
+	query.YearFrom, query.YearTo, err = parseYearParam(ctx.QueryParam("year"))
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	limit, err := parseCountParam(ctx, "limit", 0)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	offset, err := parseCountParam(ctx, "offset", 0)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
 	}
 
-	var songs []SongItem
+	matches, err := modules.SearchSongs(query)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
 
-	for _, filePath := range mp3FilePaths {
-		hash := modules.GenerateSongHash(filePath)
-		tag, err := id3v2.Open(filePath, id3v2.Options{Parse: true})
-		if err != nil {
-			modules.Logger.Error(err)
-			// If we can't read ID3 tags, use filename
-			songs = append(songs, SongItem{
-				Hash:     hash,
-				Title:    filepath.Base(filePath),
-				Artist:   "Unknown",
-				Filename: filepath.Base(filePath),
-			})
-			continue
-		}
+	type SongItem struct {
+		Hash       string `json:"hash"`
+		Title      string `json:"title"`
+		Artist     string `json:"artist"`
+		Album      string `json:"album"`
+		Genre      string `json:"genre"`
+		Year       int    `json:"year"`
+		Filename   string `json:"filename"`
+		Path       string `json:"path"`
+		Duration   int64  `json:"duration"`
+		Bitrate    int    `json:"bitrate"`
+		Likes      int    `json:"likes"`
+		Dislikes   int    `json:"dislikes"`
+		PlayCount  int    `json:"play_count"`
+		LastPlayed int64  `json:"last_played"`
+	}
 
-		title := tag.Title()
-		if title == "" {
-			title = filepath.Base(filePath)
-		}
+	total := len(matches)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && limit < total-offset {
+		end = offset + limit
+	}
 
-		artist := tag.Artist()
-		if artist == "" {
-			artist = "Unknown"
+	songs := make([]SongItem, 0, end-offset)
+	for _, match := range matches[offset:end] {
+		rating := modules.TrackRatings.Get(match.Hash)
+		relative, err := filepath.Rel(modules.Config.Directory, match.Path)
+		if err != nil {
+			relative = match.Path
 		}
-
 		songs = append(songs, SongItem{
-			Hash:     hash,
-			Title:    title,
-			Artist:   artist,
-			Filename: filepath.Base(filePath),
+			Hash:       match.Hash,
+			Title:      match.DisplayTitle(),
+			Artist:     match.DisplayArtist(),
+			Album:      match.Album,
+			Genre:      match.Genre,
+			Year:       match.Year,
+			Filename:   filepath.Base(match.Path),
+			Path:       filepath.ToSlash(relative),
+			Duration:   match.Duration,
+			Bitrate:    match.Bitrate,
+			Likes:      rating.Likes,
+			Dislikes:   rating.Dislikes,
+			PlayCount:  match.Plays.Count,
+			LastPlayed: match.Plays.LastPlayed,
 		})
-		tag.Close()
 	}
 
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
 		"status": "success",
-		"total":  len(songs),
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
 		"songs":  songs,
 	})
 }
 
+// parseYearParam parses a year (1999) or a range of years (1990-1999, 1990- or -1999)
+func parseYearParam(value string) (int, int, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	fromText, toText, isRange := strings.Cut(value, "-")
+	if !isRange {
+		toText = fromText
+	}
+
+	years := [2]int{}
+	for i, text := range []string{fromText, toText} {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		year, err := strconv.Atoi(text)
+		if err != nil || year < 0 {
+			return 0, 0, fmt.Errorf("year must be a year or a range like 1990-1999")
+		}
+		years[i] = year
+	}
+	return years[0], years[1], nil
+}
+
 // SetNextSong sets the next song to be played by its hash
 func SetNextSong(ctx echo.Context) error {
 	hash := ctx.QueryParam("hash")
@@ -253,26 +343,15 @@ func SetNextSong(ctx echo.Context) error {
 		})
 	}
 	
-	// Set the cached next hash
-	modules.MusicReader.CachedNextHash = hash
+	// Set the cached next hash; an old hash is stored as the song's current one
+	hash = modules.Library.ResolveHash(hash)
+	modules.MusicReader.SetCachedNextHash(hash)
 	
 	// Pre-transcode the song in background so it's ready when it plays
 	go modules.PreTranscodeAudioAsync(filePath)
 	
 	// Get info about the song we just set
-	tag, err := id3v2.Open(filePath, id3v2.Options{Parse: true})
-	title := filepath.Base(filePath)
-	artist := "Unknown"
-	
-	if err == nil {
-		if t := tag.Title(); t != "" {
-			title = t
-		}
-		if a := tag.Artist(); a != "" {
-			artist = a
-		}
-		tag.Close()
-	}
+	title, artist := modules.SongTitleArtist(hash)
 	
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
 		"status": "success",
@@ -309,19 +388,7 @@ func AddToPlaylist(ctx echo.Context) error {
 	modules.MusicReader.AddToPlaylist(hash)
 	
 	// Get song info
-	tag, err := id3v2.Open(filePath, id3v2.Options{Parse: true})
-	title := filepath.Base(filePath)
-	artist := "Unknown"
-	
-	if err == nil {
-		if t := tag.Title(); t != "" {
-			title = t
-		}
-		if a := tag.Artist(); a != "" {
-			artist = a
-		}
-		tag.Close()
-	}
+	title, artist := modules.SongTitleArtist(hash)
 	
 	return ctx.JSON(http.StatusOK, map[string]interface{}{
 		"status": "success",
@@ -389,19 +456,7 @@ func GetPlaylist(ctx echo.Context) error {
 			continue
 		}
 		
-		title := filepath.Base(filePath)
-		artist := "Unknown"
-		
-		tag, err := id3v2.Open(filePath, id3v2.Options{Parse: true})
-		if err == nil {
-			if t := tag.Title(); t != "" {
-				title = t
-			}
-			if a := tag.Artist(); a != "" {
-				artist = a
-			}
-			tag.Close()
-		}
+		title, artist := modules.SongTitleArtist(hash)
 		
 		items = append(items, PlaylistItem{
 			Index:    i,
@@ -527,5 +582,6 @@ func GetStreamMode(ctx echo.Context) error {
 			"hasSource": modules.IcecastSource.HasActiveSource(),
 			"bufferSize": modules.IcecastSource.BufferSize(),
 		},
+		"watchdog": modules.LiveWatchdog.Status(),
 	})
 }
\ No newline at end of file
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type smartPlaylistMatch struct {
+	Hash      string `json:"hash"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album"`
+	Genre     string `json:"genre"`
+	Year      int    `json:"year"`
+	PlayCount int    `json:"play_count"`
+	Likes     int    `json:"likes"`
+	Dislikes  int    `json:"dislikes"`
+}
+
+func smartPlaylistMatches(hashes []string) []smartPlaylistMatch {
+	matches := make([]smartPlaylistMatch, 0, len(hashes))
+	for _, hash := range hashes {
+		meta, _ := modules.GetTrackMeta(hash)
+		rating := modules.TrackRatings.Get(hash)
+		matches = append(matches, smartPlaylistMatch{
+			Hash:      hash,
+			Title:     meta.Title,
+			Artist:    meta.Artist,
+			Album:     meta.Album,
+			Genre:     meta.Genre,
+			Year:      meta.Year,
+			PlayCount: modules.PlayCounts.Get(hash).Count,
+			Likes:     rating.Likes,
+			Dislikes:  rating.Dislikes,
+		})
+	}
+	return matches
+}
+
+// GetSmartPlaylists lists the configured smart playlists with their number of matches
+func GetSmartPlaylists(ctx echo.Context) error {
+	names := make([]string, 0, len(modules.Config.SmartPlaylists))
+	for name := range modules.Config.SmartPlaylists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	active := modules.Playlists.ActiveSmart()
+	playlists := []map[string]interface{}{}
+	for _, name := range names {
+		playlist := modules.Config.SmartPlaylists[name]
+		playlists = append(playlists, map[string]interface{}{
+			"name":    name,
+			"rule":    playlist.Rule,
+			"sort":    playlist.Sort,
+			"limit":   playlist.Limit,
+			"order":   playlist.Order,
+			"active":  name == active,
+			"matches": len(playlist.Evaluate(now)),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":    "success",
+		"active":    active,
+		"playlists": playlists,
+	})
+}
+
+// PreviewSmartPlaylist returns the songs currently matching a smart playlist
+func PreviewSmartPlaylist(ctx echo.Context) error {
+	name := playlistName(ctx)
+	hashes, err := modules.SmartPlaylistSongHashes(name)
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"name":    name,
+		"rule":    modules.Config.SmartPlaylists[name].Rule,
+		"total":   len(hashes),
+		"matches": smartPlaylistMatches(hashes),
+	})
+}
+
+// PreviewSmartRule returns the songs matching a rule without saving it, for trying out rules
+// Query: rule, sort, limit
+func PreviewSmartRule(ctx echo.Context) error {
+	preview := modules.SmartPlaylist{Rule: ctx.QueryParam("rule"), Sort: ctx.QueryParam("sort")}
+	if value := ctx.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "limit must be a valid integer",
+			})
+		}
+		preview.Limit = limit
+	}
+
+	// Validated like configured smart playlists
+	playlist := map[string]modules.SmartPlaylist{"preview": preview}
+	if err := modules.PrepareSmartPlaylists(playlist); err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	hashes := playlist["preview"].Evaluate(time.Now())
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"rule":    playlist["preview"].Rule,
+		"total":   len(hashes),
+		"matches": smartPlaylistMatches(hashes),
+	})
+}
+
+// ActivateSmartPlaylist makes a smart playlist the rotation source instead of the whole library
+// DELETE /playlists/rotation returns rotation to the library
+func ActivateSmartPlaylist(ctx echo.Context) error {
+	if err := modules.Playlists.SetActiveSmart(playlistName(ctx)); err != nil {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "smart playlist is now the rotation source",
+	})
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"errors"
+	"gostream/modules"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Largest artwork accepted by EditSong
+const maxArtworkSize = 10 * 1024 * 1024
+
+func songEditError(ctx echo.Context, err error) error {
+	status := http.StatusBadRequest
+	if errors.Is(err, modules.ErrSongNotFound) {
+		status = http.StatusNotFound
+	}
+	return ctx.JSON(status, map[string]interface{}{
+		"status":  "error",
+		"message": err.Error(),
+	})
+}
+
+func songEntryResponse(entry modules.LibraryEntry) map[string]interface{} {
+	relative, err := filepath.Rel(modules.Config.Directory, entry.Path)
+	if err != nil {
+		relative = entry.Path
+	}
+	return map[string]interface{}{
+		"hash":     entry.Hash,
+		"path":     filepath.ToSlash(relative),
+		"title":    entry.Title,
+		"artist":   entry.Artist,
+		"album":    entry.Album,
+		"genre":    entry.Genre,
+		"year":     entry.Year,
+		"duration": entry.Duration,
+	}
+}
+
+// EditSong changes the ID3 tags of a song
+// Params: title, artist, album, genre, year (empty removes the tag), remove_artwork;
+// multipart field artwork sets a JPEG or PNG front cover
+func EditSong(ctx echo.Context) error {
+	params := url.Values{}
+	for key, values := range ctx.QueryParams() {
+		params[key] = values
+	}
+	if form, err := ctx.FormParams(); err == nil {
+		for key, values := range form {
+			params[key] = values
+		}
+	}
+	field := func(name string) *string {
+		if values, ok := params[name]; ok && len(values) > 0 {
+			return &values[0]
+		}
+		return nil
+	}
+
+	update := modules.TagUpdate{
+		Title:         field("title"),
+		Artist:        field("artist"),
+		Album:         field("album"),
+		Genre:         field("genre"),
+		Year:          field("year"),
+		RemoveArtwork: params.Get("remove_artwork") == "true",
+	}
+	if strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		if fileHeader, err := ctx.FormFile("artwork"); err == nil {
+			if fileHeader.Size > maxArtworkSize {
+				return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+					"status":  "error",
+					"message": "artwork exceeds 10 MB",
+				})
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				return songEditError(ctx, err)
+			}
+			update.Artwork, err = io.ReadAll(io.LimitReader(file, maxArtworkSize))
+			file.Close()
+			if err != nil {
+				return songEditError(ctx, err)
+			}
+		}
+	}
+
+	entry, err := modules.EditSongTags(ctx.Param("hash"), update)
+	if err != nil {
+		return songEditError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "tags updated",
+		"song":    songEntryResponse(entry),
+	})
+}
+
+// MoveSong renames or moves a song within the music directory
+// Params: to (path relative to the music directory)
+func MoveSong(ctx echo.Context) error {
+	target := ctx.QueryParam("to")
+	if target == "" {
+		target = ctx.FormValue("to")
+	}
+	if target == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "to parameter is required",
+		})
+	}
+
+	entry, err := modules.MoveSong(ctx.Param("hash"), target)
+	if err != nil {
+		return songEditError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "song moved",
+		"song":    songEntryResponse(entry),
+	})
+}
+
+// DeleteSong deletes a song file from the library
+func DeleteSong(ctx echo.Context) error {
+	if err := modules.DeleteSong(ctx.Param("hash")); err != nil {
+		return songEditError(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "song deleted",
+	})
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"gostream/modules"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Room for the multipart headers around the uploaded file
+const uploadFormOverhead = 1024 * 1024
+
+// UploadSong adds an uploaded MP3 file (multipart field "file") to the library.
+// The song can be played right away; transcoding and loudness analysis run in the
+// background and are polled with GetIngestStatus.
+func UploadSong(ctx echo.Context) error {
+	limit := int64(modules.Config.UploadMaxMB) * 1024 * 1024
+	request := ctx.Request()
+	if request.ContentLength > limit+uploadFormOverhead {
+		return uploadTooLarge(ctx)
+	}
+	request.Body = http.MaxBytesReader(ctx.Response(), request.Body, limit+uploadFormOverhead)
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return uploadTooLarge(ctx)
+		}
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "file field is required",
+		})
+	}
+	if fileHeader.Size > limit {
+		return uploadTooLarge(ctx)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		modules.Logger.Error(err)
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "could not read the upload",
+		})
+	}
+	defer file.Close()
+
+	job, err := modules.Ingest.IngestUpload(file, fileHeader.Filename)
+	if errors.Is(err, modules.ErrUploadTooLarge) {
+		return uploadTooLarge(ctx)
+	}
+	if errors.Is(err, modules.ErrNotMP3) {
+		return ctx.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		modules.Logger.Error(fmt.Sprintf("Upload of %s failed: %v", fileHeader.Filename, err))
+		return ctx.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "could not store the upload",
+		})
+	}
+
+	return ctx.JSON(http.StatusAccepted, map[string]interface{}{
+		"status": "success",
+		"hash":   job.Hash,
+		"ingest": job,
+	})
+}
+
+func uploadTooLarge(ctx echo.Context) error {
+	return ctx.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"status":  "error",
+		"message": fmt.Sprintf("file exceeds %d MB", modules.Config.UploadMaxMB),
+	})
+}
+
+// GetIngestStatus returns the ingest status of an uploaded song
+func GetIngestStatus(ctx echo.Context) error {
+	job, ok := modules.Ingest.Job(ctx.Param("hash"))
+	if !ok {
+		return ctx.JSON(http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": "no upload found for this hash",
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"ingest": job,
+	})
+}
+
+// GetUploads returns the recent uploads with their ingest status, newest first
+func GetUploads(ctx echo.Context) error {
+	jobs := modules.Ingest.Jobs()
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"total":   len(jobs),
+		"uploads": jobs,
+	})
+}
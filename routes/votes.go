@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"gostream/modules"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetSkipVotes returns the skip vote on the current song
+func GetSkipVotes(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"enabled": modules.Config.SkipVoteEnabled,
+		"vote":    modules.SkipVotes.Status(),
+	})
+}
+
+// VoteSkip records a listener's vote to skip the current song
+// Listeners are identified by IP, like requests
+func VoteSkip(ctx echo.Context) error {
+	vote, err := modules.SkipVotes.Vote(listenerKey(ctx))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "skip voting is disabled" {
+			status = http.StatusForbidden
+		}
+		return ctx.JSON(status, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	message := "Vote counted"
+	if vote.Skipped {
+		message = "Vote counted, skipping to next song"
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": message,
+		"vote":    vote,
+	})
+}
+
+// LikeSong records a like for a song
+// Params: hash (optional, defaults to the song on air)
+func LikeSong(ctx echo.Context) error {
+	return rateSong(ctx, true)
+}
+
+// DislikeSong records a dislike for a song
+// Params: hash (optional, defaults to the song on air)
+func DislikeSong(ctx echo.Context) error {
+	return rateSong(ctx, false)
+}
+
+func rateSong(ctx echo.Context, like bool) error {
+	hash := ctx.FormValue("hash")
+	if hash == "" {
+		current := modules.CurrentOnAir()
+		if current.Type != modules.OnAirTrack || current.Hash == "" {
+			return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "no song is on air, hash parameter is required",
+			})
+		}
+		hash = current.Hash
+	}
+	if modules.AdBreaks.IsSpot(hash) {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "song hash not found",
+		})
+	}
+
+	rating, err := modules.TrackRatings.Rate(hash, listenerKey(ctx), like)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"hash":     hash,
+		"likes":    rating.Likes,
+		"dislikes": rating.Dislikes,
+	})
+}